@@ -4,8 +4,16 @@ import (
 	"fmt"
 	"github.com/spf13/viper"
 	"log"
+	"mcpclient/llm"
+	"mcpclient/mcpserver"
+	"os"
+	"path/filepath"
+	"time"
 )
 
+// ConfigDirEnv 指定配置文件目录的环境变量，未设置时使用 ./config
+const ConfigDirEnv = "MCPCLIENT_CONFIG_DIR"
+
 type Appconfig struct {
 	Name        string `mapstructure:"name"`
 	Development string `mapstructure:"development"`
@@ -13,7 +21,7 @@ type Appconfig struct {
 }
 
 type Jwtconfig struct {
-	secretKey string `mapstructure:"secret_key"`
+	SecretKey string `mapstructure:"secret_key"`
 }
 
 type DatabaseConfig struct {
@@ -35,30 +43,111 @@ type OllamaConfig struct {
 	Model string `mapstructure:"model"`
 }
 
+type OpenAIConfig struct {
+	Name    string `mapstructure:"name"`
+	BaseURL string `mapstructure:"base_url"`
+	APIKey  string `mapstructure:"api_key"`
+	Model   string `mapstructure:"model"`
+}
+
+type AnthropicConfig struct {
+	Name      string `mapstructure:"name"`
+	BaseURL   string `mapstructure:"base_url"`
+	APIKey    string `mapstructure:"api_key"`
+	Model     string `mapstructure:"model"`
+	MaxTokens int    `mapstructure:"max_tokens"`
+}
+
+type RoutingRule struct {
+	Name            string   `mapstructure:"name"`
+	Provider        string   `mapstructure:"provider"`
+	RequiresTools   *bool    `mapstructure:"requires_tools"`
+	MinPromptLength int      `mapstructure:"min_prompt_length"`
+	MaxPromptLength int      `mapstructure:"max_prompt_length"`
+	Tiers           []string `mapstructure:"tiers"`
+}
+
+type RoutingConfig struct {
+	DefaultTier string        `mapstructure:"default_tier"`
+	FallbackOn  []string      `mapstructure:"fallback_on"`
+	Rules       []RoutingRule `mapstructure:"rules"`
+}
+
+type ModelConfig struct {
+	Name     string      `mapstructure:"name"`
+	Provider string      `mapstructure:"provider"`
+	Options  llm.Options `mapstructure:"options"`
+}
+
 type NosqldatabaseConfig struct {
 	Host           string `mapstructure:"host"`
 	Port           string `mapstructure:"port"`
 	Databasename   string `mapstructure:"databasename"`
 	Collectionname string `mapstructure:"collectionname"`
 }
+type HistoryConfig struct {
+	Store string `mapstructure:"store"`
+}
+
+type AdminConfig struct {
+	Users []string `mapstructure:"users"`
+}
+
+type MCPConfig struct {
+	HealthInterval time.Duration `mapstructure:"health_interval"`
+	PingTimeout    time.Duration `mapstructure:"ping_timeout"`
+	MinBackoff     time.Duration `mapstructure:"min_backoff"`
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+
+	ApprovalTimeout time.Duration `mapstructure:"approval_timeout"`
+	SecretsFile     string        `mapstructure:"secrets_file"`
+	ServersFile     string        `mapstructure:"servers_file"`
+
+	ToolConcurrency int           `mapstructure:"tool_concurrency"`
+	ToolTimeout     time.Duration `mapstructure:"tool_timeout"`
+}
+
+type ToolPolicyRule struct {
+	Name    string   `mapstructure:"name"`
+	Servers []string `mapstructure:"servers"`
+	Tools   []string `mapstructure:"tools"`
+	Roles   []string `mapstructure:"roles"`
+	Action  string   `mapstructure:"action"`
+}
+
+type ToolPolicyConfig struct {
+	Default string           `mapstructure:"default"`
+	Rules   []ToolPolicyRule `mapstructure:"rules"`
+}
+
 type Config struct {
 	App           Appconfig
 	Jwt           Jwtconfig
 	Database      DatabaseConfig
 	Ollama        OllamaConfig
+	Openai        OpenAIConfig
+	Anthropic     AnthropicConfig
+	Routing       RoutingConfig
+	Models        []ModelConfig
 	Nosqldatabase NosqldatabaseConfig
+	History       HistoryConfig
+	MCP           MCPConfig
+	Admin         AdminConfig
+	ToolPolicy    ToolPolicyConfig `mapstructure:"tool_policy"`
 }
 
+// LoadConfig 读取 path 目录下的 config.yaml
 func LoadConfig(path string) (config Config, err error) {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yml")
-	viper.AddConfigPath("/home/chenyun/program/Go/mcptest/mcpclient/config/")
+	v := viper.New()
+	v.SetConfigName("config")
+	v.SetConfigType("yml")
+	v.AddConfigPath(path)
 
-	if err := viper.ReadInConfig(); err != nil {
+	if err := v.ReadInConfig(); err != nil {
 		log.Fatalf("Error reading config file %v", err)
 	}
 
-	err = viper.Unmarshal(&config)
+	err = v.Unmarshal(&config)
 	if err != nil {
 		log.Fatalf("Unable to decode into struct, %v", err)
 		return
@@ -66,12 +155,20 @@ func LoadConfig(path string) (config Config, err error) {
 	return
 }
 
-// GetConfig 获取Config文件
+// GetConfig 获取Config文件，目录由环境变量 MCPCLIENT_CONFIG_DIR 指定
 func GetConfig() Config {
-	config, _ := LoadConfig("./config")
+	config, _ := LoadConfig(ConfigDir())
 	return config
 }
 
+// ConfigDir 返回配置文件所在的目录
+func ConfigDir() string {
+	if dir := os.Getenv(ConfigDirEnv); dir != "" {
+		return dir
+	}
+	return "./config"
+}
+
 func (c *Config) Getapp() Appconfig {
 	appconfig := c.App
 	return appconfig
@@ -79,7 +176,7 @@ func (c *Config) Getapp() Appconfig {
 
 func (c *Config) GetDatabasedsn() string {
 	dbConfig := c.Database
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=%t&loc=%s",
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=%s&parseTime=%s&loc=%s",
 		dbConfig.User,
 		dbConfig.Password,
 		dbConfig.Host,
@@ -93,13 +190,73 @@ func (c *Config) GetDatabasedsn() string {
 }
 
 func (c *Config) GetsecretKey() string {
-	return c.Jwt.secretKey
+	return c.Jwt.SecretKey
 }
 
 func (c *Config) Getollama() (string, string) {
 	return c.Ollama.Host + c.Ollama.Port, c.Ollama.Model
 }
 
+func (c *Config) Getopenai() (string, string) {
+	return c.Openai.BaseURL, c.Openai.APIKey
+}
+
+func (c *Config) Getanthropic() (string, string, int) {
+	return c.Anthropic.BaseURL, c.Anthropic.APIKey, c.Anthropic.MaxTokens
+}
+
+func (c *Config) Getrouting() RoutingConfig {
+	return c.Routing
+}
+
+func (c *Config) Getmodels() []ModelConfig {
+	return c.Models
+}
+
 func (c *Config) Getnosqldatabase() (string, string, string, string) {
 	return c.Nosqldatabase.Host, c.Nosqldatabase.Port, c.Nosqldatabase.Databasename, c.Nosqldatabase.Collectionname
 }
+
+// Gethistorystore 获取对话历史记录的存储方式，默认为 mongo
+func (c *Config) Gethistorystore() string {
+	if c.History.Store == "" {
+		return "mongo"
+	}
+	return c.History.Store
+}
+
+// Getmcpmanager 获取 MCP 服务器健康检查、重连、审批、密钥文件和工具调用执行的参数，未配置的项使用默认值
+// 管理员负责审批无法确定用户的采样请求
+func (c *Config) Getmcpmanager() mcpserver.ManagerOptions {
+	return mcpserver.ManagerOptions{
+		HealthInterval: c.MCP.HealthInterval,
+		PingTimeout:    c.MCP.PingTimeout,
+		MinBackoff:     c.MCP.MinBackoff,
+		MaxBackoff:     c.MCP.MaxBackoff,
+
+		ApprovalTimeout: c.MCP.ApprovalTimeout,
+		SecretsFile:     c.MCP.SecretsFile,
+		Admins:          c.Admin.Users,
+
+		ToolConcurrency: c.MCP.ToolConcurrency,
+		ToolTimeout:     c.MCP.ToolTimeout,
+	}
+}
+
+// Getmcpserversfile 获取 MCP 服务器配置文件的路径，未配置时使用配置目录下的 ssemcpserver.json
+func (c *Config) Getmcpserversfile() string {
+	if c.MCP.ServersFile == "" {
+		return filepath.Join(ConfigDir(), "ssemcpserver.json")
+	}
+	return c.MCP.ServersFile
+}
+
+// Getadminusers 获取管理员的用户 ID 列表
+func (c *Config) Getadminusers() []string {
+	return c.Admin.Users
+}
+
+// Gettoolpolicy 获取工具的使用策略
+func (c *Config) Gettoolpolicy() ToolPolicyConfig {
+	return c.ToolPolicy
+}
@@ -34,7 +34,10 @@ func ConnectMongoDB() (*mongo.Collection, error) {
 	con := GetConfig()
 	host, port, Databasename, Collectionname := con.Getnosqldatabase()
 	url := "mongodb://" + host + ":" + port
-	clientOptions := options.Client().ApplyURI(url)
+	// 未指定类型的嵌套文档（例如工具结果的 Content）解码为 map，便于转换为 JSON
+	clientOptions := options.Client().ApplyURI(url).SetBSONOptions(&options.BSONOptions{
+		DefaultDocumentM: true,
+	})
 
 	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
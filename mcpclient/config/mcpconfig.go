@@ -4,30 +4,122 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	Log "github.com/charmbracelet/log"
+	"github.com/fsnotify/fsnotify"
+	"mcpclient/mcpserver"
 	"mcpclient/models"
 )
 
+// 配置文件变化后等待一段时间再重新加载，避免编辑器保存时的多次写入触发多次加载
+const mcpConfigDebounce = 500 * time.Millisecond
+
 // LoadMCPConfig 读取并解析 JSON 配置文件
-func LoadMCPConfig(filePath string) ([]models.SSEServerConfig, error) {
+func LoadMCPConfig(filePath string) ([]models.MCPServerConfig, error) {
 
 	data, err := ioutil.ReadFile(filePath)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	// 定义一个 ServerConfig 类型的切片来解析 JSON 数组
-	var servers []models.SSEServerConfig
+	var servers []models.MCPServerConfig
 	err = json.Unmarshal(data, &servers)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("解析 %s 失败: %w", filePath, err)
+	}
+	if err := mcpserver.Validate(servers); err != nil {
+		return nil, err
 	}
 
-	//// 打印解析结果
+	//// 打印解析结果，密钥不写入输出
 	for _, server := range servers {
-		fmt.Printf("Server Name: %s\n", server.Name)
-		fmt.Printf("Server URL: %s\n", server.MCPServerURL)
+		redacted := server.Redacted()
+		if !reflect.DeepEqual(redacted, server) {
+			Log.Warn("MCP 服务器配置中包含明文密钥，建议改为引用 ${env:NAME} 或 ${secret:NAME}", "server", server.Name)
+		}
+		fmt.Printf("Server Name: %s\n", redacted.Name)
+		fmt.Printf("Server Transport: %s\n", redacted.GetTransport())
+		if redacted.GetTransport() == models.TransportStdio {
+			fmt.Printf("Server Command: %s %v\n", redacted.Command, redacted.Args)
+		} else {
+			fmt.Printf("Server URL: %s\n", redacted.MCPServerURL)
+		}
 	}
-	fmt.Printf("")
 	return servers, nil
 }
+
+// SaveMCPConfig 校验并保存 MCP 服务器配置
+// 先写入同一目录下的临时文件再重命名，监听配置文件的一方不会读到写了一半的文件
+func SaveMCPConfig(filePath string, servers []models.MCPServerConfig) error {
+	if err := mcpserver.Validate(servers); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(servers, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".mcpconfig-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filePath)
+}
+
+// WatchMCPConfig 监听 MCP 服务器配置文件，文件变化并且解析成功后调用 onChange
+// 解析失败时只记录错误，继续使用原来的配置。返回的函数用于停止监听
+func WatchMCPConfig(filePath string, onChange func([]models.MCPServerConfig)) (func(), error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// 监听所在目录而不是文件本身，编辑器保存时常常用新文件替换原文件
+	if err := watcher.Add(filepath.Dir(filePath)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	go func() {
+		var debounce <-chan time.Time
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != filepath.Clean(filePath) ||
+					!event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+					continue
+				}
+				debounce = time.After(mcpConfigDebounce)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				Log.Error("监听 MCP 配置文件出错", "file", filePath, "error", err)
+			case <-debounce:
+				debounce = nil
+				servers, err := LoadMCPConfig(filePath)
+				if err != nil {
+					Log.Error("重新加载 MCP 配置文件失败，继续使用原来的配置", "file", filePath, "error", err)
+					continue
+				}
+				onChange(servers)
+			}
+		}
+	}()
+	return func() { watcher.Close() }, nil
+}
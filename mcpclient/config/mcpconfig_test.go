@@ -0,0 +1,72 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"mcpclient/models"
+)
+
+func TestWatchMCPConfig(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "ssemcpserver.json")
+	if err := os.WriteFile(file, []byte("[]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(chan []models.MCPServerConfig, 10)
+	stop, err := WatchMCPConfig(file, func(servers []models.MCPServerConfig) {
+		changes <- servers
+	})
+	if err != nil {
+		t.Fatalf("WatchMCPConfig() error = %v", err)
+	}
+	defer stop()
+
+	// expectNoChange 等待超过防抖时间，确认没有触发重新加载
+	expectNoChange := func(what string) {
+		t.Helper()
+		select {
+		case servers := <-changes:
+			t.Fatalf("%s triggered a reload: %+v", what, servers)
+		case <-time.After(mcpConfigDebounce + 300*time.Millisecond):
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "other.json"), []byte("[]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expectNoChange("other file in the directory")
+
+	if err := os.WriteFile(file, []byte("[{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expectNoChange("invalid JSON")
+
+	// 连续保存两次只重新加载一次，读到的是最后一次的内容
+	servers := []models.MCPServerConfig{{Name: "Local", Transport: models.TransportStdio, Command: "echo"}}
+	if err := SaveMCPConfig(file, []models.MCPServerConfig{{Name: "Old", Transport: models.TransportStdio, Command: "echo"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveMCPConfig(file, servers); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-changes:
+		if !reflect.DeepEqual(got, servers) {
+			t.Errorf("onChange() got %+v, want %+v", got, servers)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for reload")
+	}
+	expectNoChange("the debounced second save")
+
+	stop()
+	if err := SaveMCPConfig(file, nil); err != nil {
+		t.Fatal(err)
+	}
+	expectNoChange("a save after stop")
+}
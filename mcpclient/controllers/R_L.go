@@ -85,7 +85,13 @@ func Loginuser(ctx *gin.Context) {
 		return
 	}
 	// 添加jwtToken
-	token, err := utils.GenerateToken(user.UserID, user.UserName)
+	secretKey, ok := ctx.MustGet("jwtSecret").([]byte)
+	if !ok {
+		log.Println("获取jwt签名密钥失败")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "初始化失败"})
+		return
+	}
+	token, err := utils.GenerateToken(secretKey, user.UserID, user.UserName, user.Tier)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"mcpclient/llm"
+	"mcpclient/models"
+	"mcpclient/policy"
+	"mcpclient/store"
+	"mcpclient/utils"
+)
+
+// 生成对话标题的超时时间
+const titleTimeout = 30 * time.Second
+
+// ListConversations 列出用户的所有对话，按最后更新时间倒序
+func ListConversations(ctx *gin.Context) {
+	historyStore, userID, ok := conversationContext(ctx)
+	if !ok {
+		return
+	}
+
+	conversations, err := historyStore.List(ctx.Request.Context(), userID)
+	if err != nil {
+		log.Println("读取对话列表失败:", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "读取对话列表失败"})
+		return
+	}
+	summaries := make([]models.ConversationSummary, 0, len(conversations))
+	for _, conversation := range conversations {
+		summaries = append(summaries, conversation.Summary())
+	}
+	ctx.JSON(http.StatusOK, gin.H{"conversations": summaries})
+}
+
+// GetConversation 获取一个对话的完整历史记录，包括工具调用和工具结果
+func GetConversation(ctx *gin.Context) {
+	historyStore, userID, ok := conversationContext(ctx)
+	if !ok {
+		return
+	}
+
+	conversation, err := historyStore.Get(ctx.Request.Context(), userID, ctx.Param("id"))
+	if err != nil {
+		conversationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, conversation)
+}
+
+// UpdateConversation 修改对话的标题或者对话中禁用的工具，只修改请求中出现的字段
+func UpdateConversation(ctx *gin.Context) {
+	historyStore, userID, ok := conversationContext(ctx)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Title         *string   `json:"title"`
+		DisabledTools *[]string `json:"disabled_tools"` // 格式为 服务器名称__工具名称 的 glob，例如 Demo__*
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if input.Title == nil && input.DisabledTools == nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "没有需要修改的字段"})
+		return
+	}
+	response := gin.H{}
+	if input.Title != nil {
+		title := strings.TrimSpace(*input.Title)
+		if title == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "标题不能为空"})
+			return
+		}
+		*input.Title = title
+		response["title"] = title
+	}
+	if input.DisabledTools != nil {
+		patterns := []string{}
+		for _, pattern := range *input.DisabledTools {
+			if err := policy.ValidatePattern(pattern); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			patterns = append(patterns, pattern)
+		}
+		*input.DisabledTools = patterns
+		response["disabled_tools"] = patterns
+	}
+
+	if input.Title != nil {
+		if err := historyStore.Rename(ctx.Request.Context(), userID, ctx.Param("id"), *input.Title); err != nil {
+			conversationError(ctx, err)
+			return
+		}
+	}
+	if input.DisabledTools != nil {
+		if err := historyStore.SetDisabledTools(ctx.Request.Context(), userID, ctx.Param("id"), *input.DisabledTools); err != nil {
+			conversationError(ctx, err)
+			return
+		}
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// DeleteConversation 删除一个对话
+func DeleteConversation(ctx *gin.Context) {
+	historyStore, userID, ok := conversationContext(ctx)
+	if !ok {
+		return
+	}
+
+	if err := historyStore.Delete(ctx.Request.Context(), userID, ctx.Param("id")); err != nil {
+		conversationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{})
+}
+
+// GenerateConversationTitle 根据对话的第一轮问答，使用配置的模型提供者生成并保存标题
+func GenerateConversationTitle(ctx *gin.Context) {
+	historyStore, userID, ok := conversationContext(ctx)
+	if !ok {
+		return
+	}
+	provider, ok := ctx.MustGet("provider").(llm.Provider)
+	if !ok {
+		log.Println("获取模型提供者失败")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "初始化失败"})
+		return
+	}
+
+	title, err := generateTitle(ctx.Request.Context(), historyStore, provider, userID, ctx.Param("id"))
+	if err != nil {
+		conversationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"title": title})
+}
+
+// generateTitle 读取对话，生成标题并保存
+func generateTitle(
+	ctx context.Context,
+	historyStore store.HistoryStore,
+	provider llm.Provider,
+	userID, conversationID string,
+) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, titleTimeout)
+	defer cancel()
+
+	conversation, err := historyStore.Get(ctx, userID, conversationID)
+	if err != nil {
+		return "", err
+	}
+	title, err := utils.GenerateTitle(ctx, provider, conversation.HistoryMessage)
+	if err != nil {
+		return "", err
+	}
+	if err := historyStore.Rename(ctx, userID, conversationID, title); err != nil {
+		return "", err
+	}
+	return title, nil
+}
+
+// conversationContext 获取对话接口共用的历史记录存储和用户 ID
+func conversationContext(ctx *gin.Context) (store.HistoryStore, string, bool) {
+	historyStore, ok := ctx.MustGet("historyStore").(store.HistoryStore)
+	if !ok {
+		log.Println("获取历史记录存储失败")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "初始化失败"})
+		return nil, "", false
+	}
+	// 用户 ID 由认证中间件从 token 中解析，存储只返回属于该用户的对话，其他用户的对话一律视为不存在
+	userID := ctx.GetString("userid")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "用户未登录"})
+		return nil, "", false
+	}
+	return historyStore, userID, true
+}
+
+// conversationError 将存储返回的错误转换为 HTTP 响应
+func conversationError(ctx *gin.Context, err error) {
+	if errors.Is(err, store.ErrConversationNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	log.Println("对话操作失败:", err)
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": "服务器出错"})
+}
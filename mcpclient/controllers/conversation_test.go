@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"mcpclient/llm/history"
+	"mcpclient/store"
+)
+
+// newConversationEngine 创建注册了对话管理接口的 gin 引擎，当前用户为请求头 X-User 中的用户
+func newConversationEngine(t *testing.T, historyStore store.HistoryStore) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(ctx *gin.Context) {
+		ctx.Set("historyStore", historyStore)
+		ctx.Set("userid", ctx.GetHeader("X-User"))
+		ctx.Next()
+	})
+	r.GET("/api/chat/conversations", ListConversations)
+	r.GET("/api/chat/conversations/:id", GetConversation)
+	r.PATCH("/api/chat/conversations/:id", UpdateConversation)
+	r.DELETE("/api/chat/conversations/:id", DeleteConversation)
+	return r
+}
+
+func TestConversationEndpoints(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		user   string
+		body   string
+		status int
+		want   string // 响应中应该包含的内容
+	}{
+		{name: "list own", method: http.MethodGet, path: "/api/chat/conversations", user: "alice", status: http.StatusOK,
+			want: `{"conversations":[{"conversationid":"1-alice","title":"","createtime":1,"updatetime":`},
+		{name: "list without conversations", method: http.MethodGet, path: "/api/chat/conversations", user: "carol", status: http.StatusOK,
+			want: `{"conversations":[]}`},
+		{name: "get own", method: http.MethodGet, path: "/api/chat/conversations/1-alice", user: "alice", status: http.StatusOK, want: `"conversationid":"1-alice"`},
+		{name: "get other user's", method: http.MethodGet, path: "/api/chat/conversations/1-bob", user: "alice", status: http.StatusNotFound},
+		{name: "rename own", method: http.MethodPatch, path: "/api/chat/conversations/1-alice", user: "alice", body: `{"title":" 问候 "}`, status: http.StatusOK, want: `{"title":"问候"}`},
+		{name: "rename other user's", method: http.MethodPatch, path: "/api/chat/conversations/1-bob", user: "alice", body: `{"title":"mine"}`, status: http.StatusNotFound},
+		{name: "disable tools on other user's", method: http.MethodPatch, path: "/api/chat/conversations/1-bob", user: "alice", body: `{"disabled_tools":["*"]}`, status: http.StatusNotFound},
+		{name: "empty title", method: http.MethodPatch, path: "/api/chat/conversations/1-alice", user: "alice", body: `{"title":"  "}`, status: http.StatusBadRequest},
+		{name: "delete own", method: http.MethodDelete, path: "/api/chat/conversations/1-alice", user: "alice", status: http.StatusOK},
+		{name: "delete other user's", method: http.MethodDelete, path: "/api/chat/conversations/1-bob", user: "alice", status: http.StatusNotFound},
+		{name: "not logged in", method: http.MethodGet, path: "/api/chat/conversations", status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			historyStore := store.NewMemoryStore()
+			for _, user := range []string{"alice", "bob"} {
+				message := history.HistoryMessage{Role: "user", Content: []history.ContentBlock{{Type: "text", Text: "hi"}}}
+				if err := historyStore.Append(context.Background(), user, "1-"+user, 1, message); err != nil {
+					t.Fatal(err)
+				}
+			}
+			r := newConversationEngine(t, historyStore)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-User", tt.user)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("body = %s, want %s", w.Body, tt.want)
+			}
+			// 其他用户的对话不受影响
+			bob, err := historyStore.Get(context.Background(), "bob", "1-bob")
+			if err != nil || bob.Title != "" || len(bob.DisabledTools) != 0 {
+				t.Errorf("bob's conversation = %+v, %v", bob, err)
+			}
+		})
+	}
+}
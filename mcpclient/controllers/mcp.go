@@ -1,26 +1,62 @@
 package controllers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"log"
+	"mcpclient/llm"
 	"mcpclient/llm/history"
+	"mcpclient/llm/routing"
+	"mcpclient/mcpserver"
 	"mcpclient/models"
+	"mcpclient/policy"
+	"mcpclient/store"
 	"mcpclient/utils"
 	"net/http"
-	"sync"
+	"strings"
 )
 
-var AllUserHistoryMessage = models.ManageHistoryMessage{
-	Data: make(map[string]*models.UserHistoryMessage),
-}
-
 func HandleUserPrompt2(ctx *gin.Context) {
-	fmt.Println("开始执行")
-	// 获取模型提供者
-	provider, _, _, err := utils.Getproviderclientstools()
-	if err != nil {
-		log.Println("获取初始化失败")
+	// 获取模型路由器
+	llmRouter, ok := ctx.MustGet("llmRouter").(*routing.Router)
+	if !ok {
+		log.Println("获取模型路由器失败")
+		ctx.String(http.StatusInternalServerError, "初始化失败")
+		return
+	}
+	// 获取 MCP 客户端和工具列表
+	mcpClients, ok := ctx.MustGet("clients").(map[string]mcpserver.Client)
+	if !ok {
+		log.Println("获取 MCP 客户端失败")
+		ctx.String(http.StatusInternalServerError, "初始化失败")
+		return
+	}
+	allTools, ok := ctx.MustGet("allTools").([]llm.Tool)
+	if !ok {
+		log.Println("获取工具列表失败")
+		ctx.String(http.StatusInternalServerError, "初始化失败")
+		return
+	}
+	// 获取 MCP 客户端管理器，用于读取用户附加的资源
+	mcpManager, ok := ctx.MustGet("mcpManager").(*mcpserver.Manager)
+	if !ok {
+		log.Println("获取 MCP 客户端管理器失败")
+		ctx.String(http.StatusInternalServerError, "初始化失败")
+		return
+	}
+	// 获取工具的使用策略
+	toolPolicy, ok := ctx.MustGet("toolPolicy").(*policy.Policy)
+	if !ok {
+		log.Println("获取工具策略失败")
+		ctx.String(http.StatusInternalServerError, "初始化失败")
+		return
+	}
+	// 获取对话历史记录存储
+	historyStore, ok := ctx.MustGet("historyStore").(store.HistoryStore)
+	if !ok {
+		log.Println("获取历史记录存储失败")
 		ctx.String(http.StatusInternalServerError, "初始化失败")
 		return
 	}
@@ -34,10 +70,10 @@ func HandleUserPrompt2(ctx *gin.Context) {
 		return
 	}
 
-	// 获取解析后的参数
-	UserID := requestData.Userid
+	// 获取解析后的参数，对话的所有者由 token 中的用户 ID 决定
+	UserID := ctx.GetString("userid")
 	if UserID == "" {
-		ctx.String(http.StatusBadRequest, "用户 ID 不能为空")
+		ctx.String(http.StatusUnauthorized, "用户未登录")
 		return
 	}
 	prompt := requestData.Prompt
@@ -45,135 +81,172 @@ func HandleUserPrompt2(ctx *gin.Context) {
 		ctx.String(http.StatusBadRequest, "提示语不能为空")
 		return
 	}
+	for server, level := range requestData.LogLevels {
+		if !mcpserver.ValidLogLevel(level) {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("服务器 %s 的日志级别 %s 不受支持", server, level),
+			})
+			return
+		}
+	}
 	createTime := requestData.Createtime
 	if createTime == 0 {
 		ctx.String(http.StatusBadRequest, "创建时间不能为0")
 		return
 	}
-	fmt.Println("createTime:" + string(createTime))
+
+	// 读取用户附加的资源，内容作为本轮问题的上下文
+	var attachments []history.ContentBlock
+	for _, ref := range requestData.Resources {
+		contents, err := mcpManager.ReadResource(ctx.Request.Context(), ref.Server, ref.URI)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("读取资源 %s 失败: %v", ref.URI, err),
+			})
+			return
+		}
+		attachments = append(attachments, utils.ResourceBlock(ref.Server, ref.URI, contents))
+	}
+
+	// 斜杠命令（例如 /Demo.summarize topic=x）按 MCP 提示模板展开为本轮的消息
+	var promptMessages []history.HistoryMessage
+	command, err := mcpManager.ParseCommand(prompt)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if command != nil {
+		result, err := mcpManager.GetPrompt(ctx.Request.Context(), command.Server, command.Prompt, command.Arguments)
+		if err != nil {
+			mcpServerError(ctx, err)
+			return
+		}
+		promptMessages = utils.PromptMessages(command.Server, result, attachments)
+		// 展开后的消息已经包含附加的上下文，不再作为普通提示词发送
+		prompt, attachments = "", nil
+	}
 
 	// 构建 key
 	key := utils.GenerateCustomId(createTime, UserID)
 
-	// 查找历史消息
-	historyMsg, ok := AllUserHistoryMessage.Data[key]
-	if !ok {
+	// 查找历史消息，对话不存在时从空的历史记录开始
+	historyMsg, err := historyStore.Get(ctx.Request.Context(), UserID, key)
+	if errors.Is(err, store.ErrConversationNotFound) {
 		historyMsg = &models.UserHistoryMessage{
+			ConversationID: key,
 			UserID:         UserID,
 			CreateTime:     createTime,
 			HistoryMessage: []history.HistoryMessage{},
 		}
-		AllUserHistoryMessage.Data[key] = historyMsg
+	} else if err != nil {
+		log.Println("读取历史记录失败:", err)
+		ctx.String(http.StatusInternalServerError, "读取历史记录失败")
+		return
+	}
+	historyLen := len(historyMsg.HistoryMessage)
+
+	// 按用户角色和对话设置过滤提供给模型的工具，执行工具调用时再检查一次
+	toolScope := toolPolicy.Scope(policy.Subject{
+		Roles:         policy.Roles(ctx.GetString("tier"), ctx.GetBool("isAdmin")),
+		DisabledTools: historyMsg.DisabledTools,
+	})
+	allTools = toolScope.Filter(allTools)
+	historyMsg.HistoryMessage = append(historyMsg.HistoryMessage, promptMessages...)
+
+	// 根据路由规则选择模型提供者，路由决策和提供者切换记录在 trace 中
+	trace := &llm.Trace{}
+	runCtx := llm.WithTrace(ctx.Request.Context(), trace)
+	// 工具调用期间转发给用户的服务器日志按用户设置的级别过滤
+	runCtx = mcpserver.WithLogLevels(runCtx, requestData.LogLevels)
+	runCtx = policy.WithScope(runCtx, toolScope)
+	// 需要确认的工具调用由当前用户审批
+	runCtx = mcpserver.WithApprover(runCtx, mcpManager.Approvals(), UserID)
+	// 一条消息中的多个工具调用按配置的并发数和超时时间执行
+	runCtx = mcpserver.WithToolExecution(runCtx, mcpManager.ToolExecution())
+	// 请求指定了模型时只能使用白名单中的模型
+	provider, decision, err := llmRouter.Select(runCtx, requestData.Model, routing.Request{
+		ToolsRequired: len(allTools) > 0,
+		PromptLength:  utils.ContextLength(historyMsg.HistoryMessage, prompt, attachments...),
+		UserTier:      ctx.GetString("tier"),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":  err.Error(),
+			"models": llmRouter.Models(),
+		})
+		return
+	}
+
+	// 请求中的生成参数覆盖模型配置中的默认值
+	opts := decision.Options.Merge(requestData.Options)
+	if err := opts.Validate(); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
 	}
+	provider = provider.WithOptions(opts)
 
 	// 创建 responseChan
-	responseChan := make(chan string, 10)
+	responseChan := make(chan models.Event, 10)
 
-	// 设置流式响应头
+	// 设置流式响应头，路由决策通过响应头返回，提供者切换情况在流结束后通过 Trailer 返回
 	ctx.Writer.Header().Set("Content-Type", "text/event-stream")
 	ctx.Writer.Header().Set("Cache-Control", "no-cache")
 	ctx.Writer.Header().Set("Connection", "keep-alive")
+	ctx.Writer.Header().Set("X-LLM-Route", decision.Rule)
+	ctx.Writer.Header().Set("X-LLM-Provider", decision.Provider)
+	ctx.Writer.Header().Set("Trailer", "X-LLM-Fallbacks")
 	ctx.Writer.Flush()
 
-	// 监听客户端断开
-	notify := ctx.Writer.CloseNotify()
-
-	// 启动 goroutine 监听 responseChan
+	// 启动 goroutine 监听 responseChan，把事件编码为 SSE 帧写入响应
+	// 客户端断开后请求的 context 会被取消，RunPrompt 随之结束；写入失败后只丢弃剩余事件，直到 channel 关闭
+	done := make(chan struct{})
 	go func() {
-		for {
-			select {
-			case response, ok := <-responseChan:
-				fmt.Println(response)
-				if !ok {
-					return // channel 关闭后退出
-				}
-				_, err := fmt.Fprintf(ctx.Writer, "%s\n", response)
-				if err != nil {
-					log.Println("写入响应失败:", err)
-					return
-				}
-				ctx.Writer.Flush() // 立即刷新缓冲区，避免客户端等待
-			case <-notify:
-				log.Println("客户端已断开连接")
-				return
+		defer close(done)
+		failed := false
+		for event := range responseChan {
+			if failed {
+				continue
+			}
+			frame, err := event.SSE()
+			if err != nil {
+				log.Println("编码事件失败:", err)
+				continue
+			}
+			if _, err := ctx.Writer.Write(frame); err != nil {
+				log.Println("写入响应失败:", err)
+				failed = true
+				continue
 			}
+			ctx.Writer.Flush() // 立即刷新缓冲区，避免客户端等待
 		}
 	}()
 
-	// 调用 RunPrompt
-	err = utils.RunPrompt(provider, prompt, &historyMsg.HistoryMessage, responseChan)
-	if err != nil {
-		log.Println("RunPrompt 出错:", err)
-		ctx.String(http.StatusInternalServerError, "运行错误")
-		return
-	}
-	fmt.Println("RunPrompt 调用完成")
-	n := len(historyMsg.HistoryMessage)
-	var allhistory string
-	for i := 0; i < n; i++ {
-		allhistory += historyMsg.HistoryMessage[i].Content[0].Text + "\n"
-	}
-	fmt.Println("总历史记录 :", allhistory)
-	// 关闭 channel，通知 goroutine 退出
+	// 调用 RunPrompt，工具调用和后续回答都在同一个流中返回
+	err = utils.RunPrompt(runCtx, provider, mcpClients, allTools, key, prompt, attachments, &historyMsg.HistoryMessage, responseChan)
+	// 关闭 channel，等待 goroutine 写完剩余的内容
 	close(responseChan)
-}
-
-func HandleUserPrompt(AllUserHistoryMessage *models.ManageHistoryMessage) {
-	fmt.Println("进入")
-	provider, _, _, err := utils.Getproviderclientstools()
-	if err != nil {
-		log.Println("获取初始化失败")
-	}
-	fmt.Println("初始化完成")
-
-	UserID := "3220921037"
-	prompt := "你是谁"
-	createTime := int64(1742894838)
-	key := utils.GenerateCustomId(createTime, UserID)
-	fmt.Println("key:", key)
-	historyMsg, ok := AllUserHistoryMessage.Data[key]
-	if !ok {
-		historyMsg = &models.UserHistoryMessage{
-			UserID:         UserID,
-			CreateTime:     createTime,
-			HistoryMessage: []history.HistoryMessage{},
+	<-done
+	ctx.Writer.Header().Set("X-LLM-Fallbacks", strings.Join(trace.Fallbacks(), ","))
+	log.Println("路由追踪:", trace.String())
+
+	// 保存本轮新增的消息，出错时也保存已经生成的部分；客户端可能已经断开，因此不使用请求的取消信号
+	if newMessages := historyMsg.HistoryMessage[historyLen:]; len(newMessages) > 0 {
+		saveCtx := context.WithoutCancel(ctx.Request.Context())
+		if saveErr := historyStore.Append(saveCtx, UserID, key, createTime, newMessages...); saveErr != nil {
+			log.Println("保存历史记录失败:", saveErr)
+		} else if historyLen == 0 && err == nil {
+			// 新对话完成第一轮问答后，在后台生成标题
+			go func() {
+				if _, err := generateTitle(saveCtx, historyStore, provider, UserID, key); err != nil {
+					log.Println("生成对话标题失败:", err)
+				}
+			}()
 		}
-		AllUserHistoryMessage.Data[key] = historyMsg
 	}
-
-	responseChan := make(chan string, 10)
-	var wg sync.WaitGroup
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-
-		for response := range responseChan {
-			fmt.Println(response)
-		}
-	}()
-
-	// 调用 RunPrompt
-	err = utils.RunPrompt(provider, prompt, &historyMsg.HistoryMessage, responseChan)
 	if err != nil {
-		fmt.Println("出错:", err)
-	} else {
-		fmt.Println("RunPrompt调用完成")
-	}
-
-	fmt.Println("+++++++++")
-	fmt.Println("RunPrompt 调用完成")
-
-	n := len(historyMsg.HistoryMessage)
-	fmt.Println("n:", n)
-	var allhistory string
-	for i := 0; i < n; i++ {
-		role := historyMsg.HistoryMessage[i].Role
-		fmt.Printf("role%s:%s\n", i, role)
-		allhistory = historyMsg.HistoryMessage[i].Content[0].Text + "\n"
-		fmt.Printf("allhistory%s:%s\n", i, allhistory)
-	}
-	wg.Wait() // 等待 goroutine 结束
-	fmt.Println("channel已关闭")
-	fmt.Println("执行完成")
+		// 错误已经通过 error 事件返回给前端
+		log.Println("RunPrompt 出错:", err)
+	}
 }
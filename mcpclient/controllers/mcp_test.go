@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"mcpclient/config"
+	"mcpclient/llm"
+	"mcpclient/llm/mock"
+	"mcpclient/llm/routing"
+	"mcpclient/mcpserver"
+	"mcpclient/models"
+	"mcpclient/policy"
+	"mcpclient/store"
+	"mcpclient/utils"
+)
+
+// newChatEngine 创建只注册了 /api/chat/send 的 gin 引擎，中间件设置的依赖直接写入 ctx
+func newChatEngine(t *testing.T, provider llm.Provider, historyStore store.HistoryStore) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	llmRouter, err := routing.NewRouter(config.RoutingConfig{}, nil, provider, "mock", nil)
+	if err != nil {
+		t.Fatalf("NewRouter() error = %v", err)
+	}
+	toolPolicy, err := policy.New(config.ToolPolicyConfig{})
+	if err != nil {
+		t.Fatalf("policy.New() error = %v", err)
+	}
+	mcpManager := mcpserver.NewManager(nil, mcpserver.ManagerOptions{})
+	t.Cleanup(mcpManager.Close)
+
+	r := gin.New()
+	r.Use(func(ctx *gin.Context) {
+		ctx.Set("llmRouter", llmRouter)
+		ctx.Set("clients", map[string]mcpserver.Client{})
+		ctx.Set("allTools", []llm.Tool{})
+		ctx.Set("mcpManager", mcpManager)
+		ctx.Set("toolPolicy", toolPolicy)
+		ctx.Set("historyStore", historyStore)
+		ctx.Set("userid", "42")
+		ctx.Set("tier", "free")
+		ctx.Next()
+	})
+	r.POST("/api/chat/send", HandleUserPrompt2)
+	return r
+}
+
+// sseEvents 返回 SSE 响应中 event 行的事件类型
+func sseEvents(t *testing.T, body string) []string {
+	t.Helper()
+	var events []string
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		if event, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestHandleUserPrompt2Events(t *testing.T) {
+	tests := []struct {
+		name    string
+		turns   []mock.Turn
+		body    string
+		status  int
+		events  []string
+		history int // 保存的历史消息数量
+	}{
+		{
+			name:    "stream",
+			turns:   []mock.Turn{{Chunks: []string{"你好", "！"}, Usage: mock.Usage{InputTokens: 3, OutputTokens: 2}}, {Text: "标题"}},
+			body:    `{"prompt":"hi","createtime":1718000000}`,
+			status:  http.StatusOK,
+			events:  []string{models.EventMessageDelta, models.EventMessageDelta, models.EventUsage, models.EventDone},
+			history: 2,
+		},
+		{
+			name:    "provider error",
+			turns:   []mock.Turn{{Error: "boom"}},
+			body:    `{"prompt":"hi","createtime":1718000000}`,
+			status:  http.StatusOK,
+			events:  []string{models.EventError},
+			history: 1,
+		},
+		{
+			name:   "empty prompt",
+			body:   `{"prompt":"","createtime":1718000000}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "missing createtime",
+			body:   `{"prompt":"hi"}`,
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			historyStore := store.NewMemoryStore()
+			engine := newChatEngine(t, mock.NewProvider(&mock.Script{Turns: tt.turns}), historyStore)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/chat/send", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != "text/event-stream" {
+				t.Errorf("Content-Type = %q", got)
+			}
+			if got := w.Header().Get("X-LLM-Provider"); got != "mock" {
+				t.Errorf("X-LLM-Provider = %q", got)
+			}
+			if events := sseEvents(t, w.Body.String()); strings.Join(events, ",") != strings.Join(tt.events, ",") {
+				t.Errorf("events = %v, want %v", events, tt.events)
+			}
+
+			conversation, err := historyStore.Get(context.Background(), "42", utils.GenerateCustomId(1718000000, "42"))
+			if err != nil {
+				t.Fatalf("historyStore.Get() error = %v", err)
+			}
+			if len(conversation.HistoryMessage) != tt.history {
+				t.Errorf("history = %d messages, want %d", len(conversation.HistoryMessage), tt.history)
+			}
+		})
+	}
+}
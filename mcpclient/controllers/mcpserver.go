@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mark3labs/mcp-go/mcp"
+	"mcpclient/config"
+	"mcpclient/mcpserver"
+	"mcpclient/models"
+)
+
+// 重新加载配置时等待新服务器完成第一次连接尝试的最长时间，超时后新服务器在后台继续连接
+const reloadTimeout = 30 * time.Second
+
+// ListMCPServers 返回所有 MCP 服务器的连接状态、工具数量和最近的错误，stdio 子进程的 stderr 只返回给管理员
+func ListMCPServers(ctx *gin.Context) {
+	manager, _, ok := mcpManagerContext(ctx)
+	if !ok {
+		return
+	}
+	servers := manager.Status()
+	if !ctx.GetBool("isAdmin") {
+		// stderr 中可能有密钥、路径等敏感信息，只返回给管理员
+		for i := range servers {
+			servers[i].Stderr = nil
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"servers": servers})
+}
+
+// ListMCPResources 返回服务器的资源列表和资源模板列表
+func ListMCPResources(ctx *gin.Context) {
+	manager, _, ok := mcpManagerContext(ctx)
+	if !ok {
+		return
+	}
+
+	resources, templates, err := manager.Resources(ctx.Param("name"))
+	if err != nil {
+		mcpServerError(ctx, err)
+		return
+	}
+	if resources == nil {
+		resources = []mcp.Resource{}
+	}
+	if templates == nil {
+		templates = []mcp.ResourceTemplate{}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"resources": resources, "templates": templates})
+}
+
+// ReadMCPResource 读取服务器上的一个资源，资源 URI 通过查询参数 uri 传入
+func ReadMCPResource(ctx *gin.Context) {
+	manager, _, ok := mcpManagerContext(ctx)
+	if !ok {
+		return
+	}
+	uri := ctx.Query("uri")
+	if uri == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "资源 uri 不能为空"})
+		return
+	}
+
+	contents, err := manager.ReadResource(ctx.Request.Context(), ctx.Param("name"), uri)
+	if err != nil {
+		mcpServerError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"contents": contents})
+}
+
+// ListMCPPrompts 返回所有服务器的提示模板，以及在对话中使用它们的斜杠命令
+func ListMCPPrompts(ctx *gin.Context) {
+	manager, _, ok := mcpManagerContext(ctx)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"prompts": manager.Prompts()})
+}
+
+// ListMCPApprovals 返回当前用户可以审批的操作（对话中需要确认的工具调用），管理员还可以看到服务器发起的采样请求
+func ListMCPApprovals(ctx *gin.Context) {
+	manager, _, ok := mcpManagerContext(ctx)
+	if !ok {
+		return
+	}
+	approvals := manager.Approvals().Pending(ctx.GetString("userid"), ctx.GetBool("isAdmin"))
+	ctx.JSON(http.StatusOK, gin.H{"approvals": approvals})
+}
+
+// DecideMCPApproval 同意或拒绝一个等待审批的操作，同意工具调用时可以修改调用参数
+func DecideMCPApproval(ctx *gin.Context) {
+	manager, _, ok := mcpManagerContext(ctx)
+	if !ok {
+		return
+	}
+
+	var requestData struct {
+		Approved  *bool          `json:"approved" binding:"required"`
+		Arguments map[string]any `json:"arguments"` // 修改后的工具参数，为空时使用模型给出的参数
+		Reason    string         `json:"reason"`    // 拒绝的原因，会告诉模型
+	}
+	if err := ctx.ShouldBindJSON(&requestData); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	decision := mcpserver.Decision{
+		Approved:  *requestData.Approved,
+		Arguments: requestData.Arguments,
+		Reason:    requestData.Reason,
+	}
+	err := manager.Approvals().Decide(ctx.Param("id"), ctx.GetString("userid"), ctx.GetBool("isAdmin"), decision)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"id": ctx.Param("id"), "approved": decision.Approved})
+}
+
+// UpdateMCPServers 用请求中的配置替换全部 MCP 服务器配置，保存到配置文件后立即生效
+func UpdateMCPServers(ctx *gin.Context) {
+	manager, configPath, ok := mcpManagerContext(ctx)
+	if !ok {
+		return
+	}
+
+	var servers []models.MCPServerConfig
+	if err := ctx.ShouldBindJSON(&servers); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := mcpserver.Validate(servers); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := config.SaveMCPConfig(configPath, servers); err != nil {
+		log.Println("保存 MCP 配置失败:", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "保存配置失败"})
+		return
+	}
+	// 使用从文件读回的配置，和随后文件监听读到的内容完全一致（例如空的 args 经过 omitempty 后变为 nil），
+	// 监听触发的第二次 Reload 中所有服务器的配置都没有变化，不会重新连接
+	saved, err := config.LoadMCPConfig(configPath)
+	if err != nil {
+		log.Println("读取 MCP 配置失败:", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "读取配置失败"})
+		return
+	}
+	reloadMCPServers(ctx, manager, saved)
+}
+
+// ReloadMCPServers 重新读取配置文件并应用
+func ReloadMCPServers(ctx *gin.Context) {
+	manager, configPath, ok := mcpManagerContext(ctx)
+	if !ok {
+		return
+	}
+
+	servers, err := config.LoadMCPConfig(configPath)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reloadMCPServers(ctx, manager, servers)
+}
+
+// reloadMCPServers 应用新的配置并返回所有服务器的状态
+func reloadMCPServers(ctx *gin.Context, manager *mcpserver.Manager, servers []models.MCPServerConfig) {
+	reloadCtx, cancel := context.WithTimeout(ctx.Request.Context(), reloadTimeout)
+	defer cancel()
+	if err := manager.Reload(reloadCtx, servers); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"servers": manager.Status()})
+}
+
+// mcpManagerContext 获取 MCP 管理接口共用的管理器和配置文件路径
+func mcpManagerContext(ctx *gin.Context) (*mcpserver.Manager, string, bool) {
+	manager, ok := ctx.MustGet("mcpManager").(*mcpserver.Manager)
+	if !ok {
+		log.Println("获取 MCP 客户端管理器失败")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "初始化失败"})
+		return nil, "", false
+	}
+	return manager, ctx.GetString("mcpConfigPath"), true
+}
+
+// mcpServerError 将管理器返回的错误转换为 HTTP 响应
+func mcpServerError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, mcpserver.ErrServerNotFound), errors.Is(err, mcpserver.ErrPromptNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, mcpserver.ErrInvalidPromptArguments):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, mcpserver.ErrServerUnavailable):
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		log.Println("MCP 服务器请求失败:", err)
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	}
+}
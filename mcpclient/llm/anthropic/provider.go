@@ -0,0 +1,447 @@
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"mcpclient/llm"
+	"mcpclient/llm/history"
+)
+
+const (
+	defaultBaseURL   = "https://api.anthropic.com" // 未配置 base_url 时使用的默认地址
+	apiVersion       = "2023-06-01"                // anthropic-version 请求头
+	defaultMaxTokens = 4096                        // Messages API 要求必须指定 max_tokens
+)
+
+// Provider 实现了 Anthropic Messages API 提供者接口
+type Provider struct {
+	client    *http.Client // HTTP 客户端
+	baseURL   string       // 服务地址
+	apiKey    string       // API Key
+	model     string       // 使用的模型名称
+	maxTokens int          // 单次响应的最大 token 数
+	options   llm.Options  // 生成参数（seed、num_ctx 不适用于该协议）
+}
+
+// NewProvider 创建一个新的 Anthropic 提供者实例
+// baseURL 为空时使用官方地址，apiKey 为空时从环境变量 ANTHROPIC_API_KEY 读取
+func NewProvider(baseURL, apiKey, model string, maxTokens int) (*Provider, error) {
+	if model == "" {
+		return nil, fmt.Errorf("anthropic: 模型名称不能为空")
+	}
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	if apiKey == "" {
+		apiKey = os.Getenv("ANTHROPIC_API_KEY")
+	}
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+	return &Provider{
+		client:    http.DefaultClient,
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		apiKey:    apiKey,
+		model:     model,
+		maxTokens: maxTokens,
+	}, nil
+}
+
+// CreateMessage 创建并返回一条消息
+func (p *Provider) CreateMessage(
+	ctx context.Context,
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) (llm.Message, error) {
+	log.Debug("creating message",
+		"prompt", prompt,
+		"num_messages", len(messages),
+		"num_tools", len(tools))
+
+	req := p.buildRequest(prompt, messages, tools)
+
+	resp, err := p.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var msgResp messagesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&msgResp); err != nil {
+		return nil, fmt.Errorf("anthropic: 解析响应失败: %w", err)
+	}
+
+	return &AnthropicMessage{
+		Role:       msgResp.Role,
+		Content:    msgResp.Content,
+		StopReason: msgResp.StopReason,
+		Usage:      msgResp.Usage,
+	}, nil
+}
+
+// CreateMessagestream 以流式方式创建消息，text_delta 分片会写入 contentChan
+// tool_use 的参数通过 input_json_delta 分片拼接，在 content_block_stop 时完成
+func (p *Provider) CreateMessagestream(
+	ctx context.Context,
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+	contentChan chan<- string,
+) (llm.Message, error) {
+	log.Debug("creating message stream",
+		"prompt", prompt,
+		"num_messages", len(messages),
+		"num_tools", len(tools))
+
+	req := p.buildRequest(prompt, messages, tools)
+	req.Stream = true
+
+	resp, err := p.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	response := &AnthropicMessage{Role: "assistant"}
+	var blocks []contentBlock
+	var partialJSON []strings.Builder // 每个内容块对应的参数分片
+
+	var eventType string
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event:") {
+			eventType = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			continue
+		}
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		var event streamEvent
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, fmt.Errorf("anthropic: 解析 %s 事件失败: %w", eventType, err)
+		}
+		if eventType == "" {
+			eventType = event.Type
+		}
+
+		switch eventType {
+		case "message_start":
+			if event.Message != nil {
+				if event.Message.Role != "" {
+					response.Role = event.Message.Role
+				}
+				response.Usage = event.Message.Usage
+			}
+
+		case "content_block_start":
+			for len(blocks) <= event.Index {
+				blocks = append(blocks, contentBlock{})
+				partialJSON = append(partialJSON, strings.Builder{})
+			}
+			if event.ContentBlock != nil {
+				blocks[event.Index] = *event.ContentBlock
+				blocks[event.Index].Input = nil // 参数由后续的 input_json_delta 给出
+			}
+
+		case "content_block_delta":
+			if event.Delta == nil || event.Index >= len(blocks) {
+				break
+			}
+			switch event.Delta.Type {
+			case "text_delta":
+				if event.Delta.Text != "" {
+					// 调用方不再读取时（例如客户端断开）通过 ctx 退出，避免阻塞
+					select {
+					case contentChan <- event.Delta.Text:
+					case <-ctx.Done():
+						return nil, ctx.Err()
+					}
+					blocks[event.Index].Text += event.Delta.Text
+				}
+			case "input_json_delta":
+				partialJSON[event.Index].WriteString(event.Delta.PartialJSON)
+			}
+
+		case "content_block_stop":
+			if event.Index < len(blocks) && blocks[event.Index].Type == "tool_use" {
+				input := partialJSON[event.Index].String()
+				if strings.TrimSpace(input) == "" {
+					input = "{}"
+				}
+				blocks[event.Index].Input = json.RawMessage(input)
+			}
+
+		case "message_delta":
+			if event.Delta != nil && event.Delta.StopReason != "" {
+				response.StopReason = event.Delta.StopReason
+			}
+			if event.Usage != nil {
+				response.Usage.OutputTokens = event.Usage.OutputTokens
+			}
+
+		case "error":
+			if event.Error != nil {
+				return nil, fmt.Errorf("anthropic: %s: %s", event.Error.Type, event.Error.Message)
+			}
+			return nil, fmt.Errorf("anthropic: 流式响应出错: %s", data)
+
+		case "ping", "message_stop":
+			// 无需处理
+		}
+		eventType = ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("anthropic: 读取流式响应失败: %w", err)
+	}
+
+	response.Content = blocks
+	return response, nil
+}
+
+// SupportsTools Messages API 本身支持工具调用
+func (p *Provider) SupportsTools() bool {
+	return true
+}
+
+// Name 返回提供者的名称
+func (p *Provider) Name() string {
+	return "anthropic"
+}
+
+// WithOptions 返回一个使用指定生成参数的提供者副本
+func (p *Provider) WithOptions(opts llm.Options) llm.Provider {
+	cp := *p
+	cp.options = opts
+	return &cp
+}
+
+// CreateToolResponse 创建并返回工具响应消息（包含一个 tool_result 内容块的 user 消息）
+func (p *Provider) CreateToolResponse(
+	toolCallID string,
+	content interface{},
+) (llm.Message, error) {
+	log.Debug("creating tool response",
+		"tool_call_id", toolCallID,
+		"content_type", fmt.Sprintf("%T", content))
+
+	contentStr := ""
+	switch v := content.(type) {
+	case string:
+		contentStr = v
+	default:
+		bytes, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling tool response: %w", err)
+		}
+		contentStr = string(bytes)
+	}
+
+	return &AnthropicMessage{
+		Role: "user",
+		Content: []contentBlock{{
+			Type:      "tool_result",
+			ToolUseID: toolCallID,
+			Content:   contentStr,
+		}},
+	}, nil
+}
+
+// buildRequest 将消息和工具转换为 Messages API 请求
+func (p *Provider) buildRequest(
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) *messagesRequest {
+	system, apiMessages := convertMessages(messages)
+	if prompt != "" {
+		apiMessages = appendMessage(apiMessages, message{
+			Role:    "user",
+			Content: []contentBlock{{Type: "text", Text: prompt}},
+		})
+	}
+
+	maxTokens := p.maxTokens
+	if p.options.MaxTokens != nil {
+		maxTokens = *p.options.MaxTokens
+	}
+	req := &messagesRequest{
+		Model:         p.model,
+		MaxTokens:     maxTokens,
+		System:        system,
+		Messages:      apiMessages,
+		Temperature:   p.options.Temperature,
+		TopP:          p.options.TopP,
+		StopSequences: p.options.Stop,
+	}
+	for _, t := range tools {
+		req.Tools = append(req.Tools, tool{
+			Name:        t.Name,
+			Description: t.Description,
+			InputSchema: t.InputSchema.Map(),
+		})
+	}
+	return req
+}
+
+// post 发送请求，非 2xx 响应会被转换为错误
+func (p *Provider) post(ctx context.Context, body *messagesRequest) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: 序列化请求失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		p.baseURL+"/v1/messages",
+		bytes.NewReader(data),
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("anthropic-version", apiVersion)
+	if p.apiKey != "" {
+		req.Header.Set("x-api-key", p.apiKey)
+	}
+	if body.Stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	// 错误类型（例如 overloaded_error）保留在错误信息中，以便上层的重试机制识别
+	raw, _ := io.ReadAll(resp.Body)
+	var errResp errorResponse
+	if err := json.Unmarshal(raw, &errResp); err == nil && errResp.Error.Type != "" {
+		return nil, fmt.Errorf("anthropic: %s: %s", errResp.Error.Type, errResp.Error.Message)
+	}
+	return nil, fmt.Errorf("anthropic: 请求失败 (status %d): %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+}
+
+// convertMessages 将 llm.Message 列表转换为 Messages API 消息
+// system 消息会被提取出来作为顶层的 system 参数
+func convertMessages(messages []llm.Message) (string, []message) {
+	var systems []string
+	var result []message
+
+	for _, msg := range messages {
+		if msg.GetRole() == "system" {
+			if content := msg.GetContent(); content != "" {
+				systems = append(systems, content)
+			}
+			continue
+		}
+
+		var apiMsg message
+		if historyMsg, ok := msg.(*history.HistoryMessage); ok {
+			apiMsg = convertHistoryMessage(historyMsg)
+		} else {
+			apiMsg = convertGenericMessage(msg)
+		}
+		if len(apiMsg.Content) == 0 {
+			continue
+		}
+		result = appendMessage(result, apiMsg)
+	}
+
+	return strings.Join(systems, "\n\n"), result
+}
+
+// convertHistoryMessage 将历史消息的内容块一一映射为 Messages API 内容块
+func convertHistoryMessage(msg *history.HistoryMessage) message {
+	apiMsg := message{Role: msg.Role}
+	for _, block := range msg.Content {
+		switch block.Type {
+		case "text":
+			if block.Text == "" {
+				continue
+			}
+			apiMsg.Content = append(apiMsg.Content, contentBlock{
+				Type: "text",
+				Text: block.Text,
+			})
+		case "tool_use":
+			input := block.Input
+			if len(input) == 0 {
+				input = json.RawMessage("{}")
+			}
+			apiMsg.Content = append(apiMsg.Content, contentBlock{
+				Type:  "tool_use",
+				ID:    block.ID,
+				Name:  block.Name,
+				Input: input,
+			})
+		case "tool_result":
+			apiMsg.Role = "user" // tool_result 只能出现在 user 消息中
+			apiMsg.Content = append(apiMsg.Content, contentBlock{
+				Type:      "tool_result",
+				ToolUseID: block.ToolUseID,
+				Content:   block.GetResultText(),
+			})
+		}
+	}
+	return apiMsg
+}
+
+// convertGenericMessage 转换其他实现了 llm.Message 接口的消息
+func convertGenericMessage(msg llm.Message) message {
+	if msg.IsToolResponse() {
+		return message{
+			Role: "user",
+			Content: []contentBlock{{
+				Type:      "tool_result",
+				ToolUseID: msg.GetToolResponseID(),
+				Content:   msg.GetContent(),
+			}},
+		}
+	}
+
+	apiMsg := message{Role: msg.GetRole()}
+	if content := msg.GetContent(); content != "" {
+		apiMsg.Content = append(apiMsg.Content, contentBlock{Type: "text", Text: content})
+	}
+	for _, call := range msg.GetToolCalls() {
+		input, err := json.Marshal(call.GetArguments())
+		if err != nil || call.GetArguments() == nil {
+			input = []byte("{}")
+		}
+		apiMsg.Content = append(apiMsg.Content, contentBlock{
+			Type:  "tool_use",
+			ID:    call.GetID(),
+			Name:  call.GetName(),
+			Input: input,
+		})
+	}
+	return apiMsg
+}
+
+// appendMessage 追加消息，Messages API 要求角色交替出现，相同角色的相邻消息会被合并
+func appendMessage(messages []message, msg message) []message {
+	if n := len(messages); n > 0 && messages[n-1].Role == msg.Role {
+		messages[n-1].Content = append(messages[n-1].Content, msg.Content...)
+		return messages
+	}
+	return append(messages, msg)
+}
@@ -0,0 +1,190 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"mcpclient/llm"
+	"mcpclient/llm/history"
+)
+
+// newReplayProvider 创建一个提供者，请求由 httptest 服务器用 testdata 中录制的 SSE 响应回放
+func newReplayProvider(t *testing.T, fixture string, request *messagesRequest) *Provider {
+	t.Helper()
+	stream, err := os.ReadFile("testdata/" + fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/messages" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("x-api-key"); got != "sk-ant-test" {
+			t.Errorf("x-api-key = %q", got)
+		}
+		if got := r.Header.Get("anthropic-version"); got != apiVersion {
+			t.Errorf("anthropic-version = %q", got)
+		}
+		if request != nil {
+			if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+				t.Errorf("decode request: %v", err)
+			}
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write(stream)
+	}))
+	t.Cleanup(server.Close)
+	p, err := NewProvider(server.URL, "sk-ant-test", "claude-test", 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestCreateMessagestreamToolUse(t *testing.T) {
+	var request messagesRequest
+	p := newReplayProvider(t, "stream_tool_use.sse", &request)
+
+	tools := []llm.Tool{{
+		Name:        "Weather__forecast",
+		Description: "天气预报",
+		InputSchema: llm.Schema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"city": map[string]interface{}{"$ref": "#/$defs/city"},
+			},
+			AdditionalProperties: false,
+			Defs: map[string]interface{}{
+				"city": map[string]interface{}{"type": "string"},
+			},
+		},
+	}}
+	messages := []llm.Message{
+		&history.HistoryMessage{Role: "system", Content: []history.ContentBlock{{Type: "text", Text: "你是天气助手"}}},
+	}
+	contentChan := make(chan string, 10)
+	message, err := p.CreateMessagestream(context.Background(), "北京天气", messages, tools, contentChan)
+	if err != nil {
+		t.Fatalf("CreateMessagestream() error = %v", err)
+	}
+	close(contentChan)
+
+	// 请求
+	if !request.Stream || request.Model != "claude-test" || request.MaxTokens != 1024 || request.System != "你是天气助手" {
+		t.Errorf("request = %+v", request)
+	}
+	if len(request.Messages) != 1 || request.Messages[0].Role != "user" || request.Messages[0].Content[0].Text != "北京天气" {
+		t.Errorf("messages = %+v", request.Messages)
+	}
+	if len(request.Tools) != 1 {
+		t.Fatalf("tools = %+v", request.Tools)
+	}
+	schema, _ := json.Marshal(request.Tools[0].InputSchema)
+	wantSchema := `{"$defs":{"city":{"type":"string"}},"additionalProperties":false,"properties":{"city":{"$ref":"#/$defs/city"}},"type":"object"}`
+	if string(schema) != wantSchema {
+		t.Errorf("input_schema = %s, want %s", schema, wantSchema)
+	}
+
+	// 响应
+	var chunks []string
+	for chunk := range contentChan {
+		chunks = append(chunks, chunk)
+	}
+	if strings.Join(chunks, "|") != "好的，|我来查一下北京的天气。" {
+		t.Errorf("chunks = %q", chunks)
+	}
+	if message.GetRole() != "assistant" || message.GetContent() != "好的，我来查一下北京的天气。" {
+		t.Errorf("message = %q (%s)", message.GetContent(), message.GetRole())
+	}
+	if stop := message.(*AnthropicMessage).StopReason; stop != "tool_use" {
+		t.Errorf("stop_reason = %q", stop)
+	}
+	if input, output := message.GetUsage(); input != 472 || output != 89 {
+		t.Errorf("usage = %d, %d", input, output)
+	}
+
+	toolCalls := message.GetToolCalls()
+	want := []struct {
+		id        string
+		name      string
+		arguments map[string]interface{}
+	}{
+		{"toolu_01T1x1fJ34qAmk2tNTrN7Up6", "Weather__forecast", map[string]interface{}{"city": "北京", "days": float64(3)}},
+		{"toolu_01Nw2kUyqUgFx4sUnLnwDC8r", "Weather__now", map[string]interface{}{}},
+	}
+	if len(toolCalls) != len(want) {
+		t.Fatalf("tool calls = %d, want %d", len(toolCalls), len(want))
+	}
+	for i, call := range toolCalls {
+		if call.GetID() != want[i].id || call.GetName() != want[i].name || !reflect.DeepEqual(call.GetArguments(), want[i].arguments) {
+			t.Errorf("tool call %d = %s %s %v", i, call.GetID(), call.GetName(), call.GetArguments())
+		}
+	}
+}
+
+func TestCreateMessagestreamError(t *testing.T) {
+	p := newReplayProvider(t, "stream_overloaded.sse", nil)
+	contentChan := make(chan string, 10)
+	_, err := p.CreateMessagestream(context.Background(), "你好", nil, nil, contentChan)
+	close(contentChan)
+
+	// 错误类型保留在错误信息中，上层据此判断是否重试
+	if err == nil || err.Error() != "anthropic: overloaded_error: Overloaded" {
+		t.Fatalf("error = %v", err)
+	}
+	var chunks []string
+	for chunk := range contentChan {
+		chunks = append(chunks, chunk)
+	}
+	if strings.Join(chunks, "") != "你好" {
+		t.Errorf("chunks = %q", chunks)
+	}
+}
+
+func TestCreateMessagestreamStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(529)
+		io.WriteString(w, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+	}))
+	defer server.Close()
+	p, err := NewProvider(server.URL, "sk-ant-test", "claude-test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.CreateMessagestream(context.Background(), "你好", nil, nil, make(chan string, 1))
+	if err == nil || err.Error() != "anthropic: overloaded_error: Overloaded" {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestCreateMessagestreamCanceled(t *testing.T) {
+	p := newReplayProvider(t, "stream_tool_use.sse", nil)
+
+	// 没有人读取 contentChan 时，取消 ctx 后应该返回而不是一直阻塞
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.CreateMessagestream(ctx, "你好", nil, nil, make(chan string))
+		done <- err
+	}()
+	// 等提供者阻塞在写入 contentChan 上之后再取消
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("error = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("CreateMessagestream() blocked after ctx was canceled")
+	}
+}
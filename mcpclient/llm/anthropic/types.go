@@ -0,0 +1,192 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"strings"
+
+	"mcpclient/llm"
+)
+
+// ==========================
+// Messages API 协议结构
+// ==========================
+
+// messagesRequest 对应 POST /v1/messages 的请求体
+type messagesRequest struct {
+	Model         string    `json:"model"`
+	MaxTokens     int       `json:"max_tokens"`
+	System        string    `json:"system,omitempty"`
+	Messages      []message `json:"messages"`
+	Tools         []tool    `json:"tools,omitempty"`
+	Temperature   *float64  `json:"temperature,omitempty"`
+	TopP          *float64  `json:"top_p,omitempty"`
+	StopSequences []string  `json:"stop_sequences,omitempty"`
+	Stream        bool      `json:"stream,omitempty"`
+}
+
+// message 表示一条 Messages API 消息，角色只能是 user 或 assistant
+type message struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+// contentBlock 与 history.ContentBlock 一一对应的内容块
+// - text：Text
+// - tool_use：ID、Name、Input
+// - tool_result：ToolUseID、Content
+type contentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
+// tool 表示一个可供模型调用的工具
+type tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+// messagesResponse 非流式响应，以及流式 message_start 事件中的 message
+type messagesResponse struct {
+	ID         string         `json:"id"`
+	Type       string         `json:"type"`
+	Role       string         `json:"role"`
+	Model      string         `json:"model"`
+	Content    []contentBlock `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      usage          `json:"usage"`
+}
+
+// usage token 使用情况
+type usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// apiError 错误响应以及流式 error 事件中的错误信息
+type apiError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// errorResponse 错误响应体
+type errorResponse struct {
+	Type  string   `json:"type"`
+	Error apiError `json:"error"`
+}
+
+// streamEvent 流式响应中各类 SSE 事件的数据
+type streamEvent struct {
+	Type         string            `json:"type"`
+	Message      *messagesResponse `json:"message,omitempty"`       // message_start
+	Index        int               `json:"index"`                   // content_block_*
+	ContentBlock *contentBlock     `json:"content_block,omitempty"` // content_block_start
+	Delta        *streamDelta      `json:"delta,omitempty"`         // content_block_delta、message_delta
+	Usage        *usage            `json:"usage,omitempty"`         // message_delta
+	Error        *apiError         `json:"error,omitempty"`         // error
+}
+
+// streamDelta 增量内容
+type streamDelta struct {
+	Type        string `json:"type"`
+	Text        string `json:"text,omitempty"`         // text_delta
+	PartialJSON string `json:"partial_json,omitempty"` // input_json_delta
+	StopReason  string `json:"stop_reason,omitempty"`  // message_delta
+}
+
+// ==========================
+// 适配 llm.Message 接口
+// ==========================
+
+// AnthropicMessage 将 Messages API 的响应适配为我们自己的 Message 接口
+type AnthropicMessage struct {
+	Role       string         // 消息的角色
+	Content    []contentBlock // 响应的内容块
+	StopReason string         // 停止原因，例如 end_turn、tool_use
+	Usage      usage          // token 使用情况
+}
+
+// 获取消息的角色
+func (m *AnthropicMessage) GetRole() string {
+	return m.Role
+}
+
+// 获取消息的所有文本内容
+func (m *AnthropicMessage) GetContent() string {
+	var texts []string
+	for _, block := range m.Content {
+		if block.Type == "text" {
+			texts = append(texts, block.Text)
+		}
+	}
+	return strings.TrimSpace(strings.Join(texts, ""))
+}
+
+// 获取工具调用（tool_use 内容块）
+func (m *AnthropicMessage) GetToolCalls() []llm.ToolCall {
+	var calls []llm.ToolCall
+	for _, block := range m.Content {
+		if block.Type == "tool_use" {
+			calls = append(calls, &AnthropicToolCall{block: block})
+		}
+	}
+	return calls
+}
+
+// 获取消息的 token 使用情况
+func (m *AnthropicMessage) GetUsage() (int, int) {
+	return m.Usage.InputTokens, m.Usage.OutputTokens
+}
+
+// 判断消息是否为工具响应
+func (m *AnthropicMessage) IsToolResponse() bool {
+	for _, block := range m.Content {
+		if block.Type == "tool_result" {
+			return true
+		}
+	}
+	return false
+}
+
+// 获取工具响应的 ID
+func (m *AnthropicMessage) GetToolResponseID() string {
+	for _, block := range m.Content {
+		if block.Type == "tool_result" {
+			return block.ToolUseID
+		}
+	}
+	return ""
+}
+
+// AnthropicToolCall 将 tool_use 内容块适配为我们自己的工具调用格式
+type AnthropicToolCall struct {
+	block contentBlock // 储存 tool_use 内容块
+}
+
+// 获取工具调用的名称
+func (t *AnthropicToolCall) GetName() string {
+	return t.block.Name
+}
+
+// 获取工具调用的参数
+func (t *AnthropicToolCall) GetArguments() map[string]interface{} {
+	args := make(map[string]interface{})
+	if len(t.block.Input) == 0 {
+		return args
+	}
+	if err := json.Unmarshal(t.block.Input, &args); err != nil {
+		return make(map[string]interface{}) // 解析失败返回空 map
+	}
+	return args
+}
+
+// 获取工具调用的 ID
+func (t *AnthropicToolCall) GetID() string {
+	return t.block.ID
+}
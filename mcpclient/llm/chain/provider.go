@@ -0,0 +1,146 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"mcpclient/llm"
+)
+
+// Provider 由多个提供者组成的组合提供者
+// 按顺序尝试各个成员，当错误属于配置的类别时切换到下一个成员
+type Provider struct {
+	members    []llm.Provider          // 按优先级排列的成员
+	fallbackOn map[llm.ErrorClass]bool // 触发切换的错误类别
+}
+
+// NewProvider 创建一个新的组合提供者实例
+// fallbackOn 为空时使用 llm.DefaultFallbackClasses
+func NewProvider(members []llm.Provider, fallbackOn []llm.ErrorClass) (*Provider, error) {
+	if len(members) == 0 {
+		return nil, errors.New("chain: 至少需要一个成员提供者")
+	}
+	if len(fallbackOn) == 0 {
+		fallbackOn = llm.DefaultFallbackClasses
+	}
+	classes := make(map[llm.ErrorClass]bool, len(fallbackOn))
+	for _, class := range fallbackOn {
+		classes[class] = true
+	}
+	return &Provider{members: members, fallbackOn: classes}, nil
+}
+
+// CreateMessage 依次尝试各成员创建消息
+func (p *Provider) CreateMessage(
+	ctx context.Context,
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) (llm.Message, error) {
+	return p.try(ctx, func(member llm.Provider) (llm.Message, bool, error) {
+		msg, err := member.CreateMessage(ctx, prompt, messages, tools)
+		return msg, false, err
+	})
+}
+
+// CreateMessagestream 依次尝试各成员以流式方式创建消息
+// 如果某个成员已经输出了部分内容后才失败，不再切换，避免客户端收到重复的内容
+func (p *Provider) CreateMessagestream(
+	ctx context.Context,
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+	contentChan chan<- string,
+) (llm.Message, error) {
+	return p.try(ctx, func(member llm.Provider) (llm.Message, bool, error) {
+		// 通过中间通道转发内容，以便知道成员是否已经输出
+		proxy := make(chan string)
+		done := make(chan struct{})
+		emitted := false
+		go func() {
+			defer close(done)
+			for chunk := range proxy {
+				emitted = true
+				contentChan <- chunk
+			}
+		}()
+		msg, err := member.CreateMessagestream(ctx, prompt, messages, tools, proxy)
+		close(proxy)
+		<-done
+		return msg, emitted, err
+	})
+}
+
+// try 按顺序调用成员，记录每次尝试并根据错误类别决定是否切换
+func (p *Provider) try(
+	ctx context.Context,
+	call func(member llm.Provider) (llm.Message, bool, error),
+) (llm.Message, error) {
+	trace := llm.TraceFromContext(ctx)
+
+	var lastErr error
+	for i, member := range p.members {
+		msg, emitted, err := call(member)
+		if err == nil {
+			trace.AddAttempt(llm.Attempt{Provider: member.Name()})
+			return msg, nil
+		}
+
+		class := llm.ClassifyError(err)
+		trace.AddAttempt(llm.Attempt{
+			Provider: member.Name(),
+			Error:    err.Error(),
+			Class:    class,
+		})
+		lastErr = err
+
+		if emitted || !p.fallbackOn[class] || i == len(p.members)-1 {
+			break
+		}
+		log.Warn("提供者调用失败，切换到备用提供者",
+			"provider", member.Name(),
+			"class", class,
+			"error", err,
+			"next", p.members[i+1].Name())
+	}
+	return nil, lastErr
+}
+
+// CreateToolResponse 使用第一个成员创建工具响应消息
+func (p *Provider) CreateToolResponse(
+	toolCallID string,
+	content interface{},
+) (llm.Message, error) {
+	return p.members[0].CreateToolResponse(toolCallID, content)
+}
+
+// SupportsTools 只要有一个成员支持工具调用即返回 true
+func (p *Provider) SupportsTools() bool {
+	for _, member := range p.members {
+		if member.SupportsTools() {
+			return true
+		}
+	}
+	return false
+}
+
+// WithOptions 返回一个所有成员都使用指定生成参数的组合提供者副本
+func (p *Provider) WithOptions(opts llm.Options) llm.Provider {
+	members := make([]llm.Provider, len(p.members))
+	for i, member := range p.members {
+		members[i] = member.WithOptions(opts)
+	}
+	return &Provider{members: members, fallbackOn: p.fallbackOn}
+}
+
+// Name 返回提供者的名称，例如 chain(ollama,openai)
+func (p *Provider) Name() string {
+	names := make([]string, len(p.members))
+	for i, member := range p.members {
+		names[i] = member.Name()
+	}
+	return fmt.Sprintf("chain(%s)", strings.Join(names, ","))
+}
@@ -0,0 +1,99 @@
+package chain
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"mcpclient/llm"
+	"mcpclient/llm/mock"
+)
+
+// named 带名称的 mock 提供者，用于区分 trace 中的各个成员
+type named struct {
+	*mock.Provider
+	name string
+}
+
+func (p named) Name() string { return p.name }
+
+func TestFallback(t *testing.T) {
+	tests := []struct {
+		name       string
+		primary    mock.Turn
+		fallbackOn []llm.ErrorClass
+		stream     bool
+		content    string   // 期望的回复内容，为空表示期望失败
+		attempts   []string // 期望的尝试顺序，格式为 提供者 或 提供者:错误类别
+		chunks     string   // 流式模式下期望写入 contentChan 的内容
+	}{
+		{name: "success", primary: mock.Turn{Text: "主"}, content: "主", attempts: []string{"primary"}},
+		{name: "overloaded", primary: mock.Turn{Error: "overloaded_error"}, content: "备", attempts: []string{"primary:overloaded", "backup"}},
+		{name: "rate limit", primary: mock.Turn{Error: "openai: overloaded_error (status 429): slow down"}, content: "备", attempts: []string{"primary:rate_limit", "backup"}},
+		{name: "server error", primary: mock.Turn{Error: "status 500"}, content: "备", attempts: []string{"primary:server", "backup"}},
+		{name: "other errors do not fall back", primary: mock.Turn{Error: "status 401: invalid api key"}, attempts: []string{"primary:other"}},
+		{
+			name:       "class not configured",
+			primary:    mock.Turn{Error: "status 429"},
+			fallbackOn: []llm.ErrorClass{llm.ErrorOverloaded},
+			attempts:   []string{"primary:rate_limit"},
+		},
+		{name: "stream fallback before output", primary: mock.Turn{Error: "overloaded_error"}, stream: true, content: "备", attempts: []string{"primary:overloaded", "backup"}, chunks: "备"},
+		{
+			name:     "no fallback after output started",
+			primary:  mock.Turn{Chunks: []string{"一半"}, Error: "overloaded_error"},
+			stream:   true,
+			attempts: []string{"primary:overloaded"},
+			chunks:   "一半",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := named{mock.NewProvider(&mock.Script{Turns: []mock.Turn{tt.primary}}), "primary"}
+			backup := named{mock.NewProvider(&mock.Script{Turns: []mock.Turn{{Text: "备"}}}), "backup"}
+			provider, err := NewProvider([]llm.Provider{primary, backup}, tt.fallbackOn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			trace := &llm.Trace{}
+			ctx := llm.WithTrace(context.Background(), trace)
+
+			var msg llm.Message
+			var chunks strings.Builder
+			if tt.stream {
+				contentChan := make(chan string, 10)
+				msg, err = provider.CreateMessagestream(ctx, "hi", nil, nil, contentChan)
+				close(contentChan)
+				for chunk := range contentChan {
+					chunks.WriteString(chunk)
+				}
+			} else {
+				msg, err = provider.CreateMessage(ctx, "hi", nil, nil)
+			}
+
+			if tt.content == "" {
+				if err == nil {
+					t.Fatalf("CreateMessage() = %v, want error", msg.GetContent())
+				}
+			} else if err != nil || msg.GetContent() != tt.content {
+				t.Fatalf("CreateMessage() = %v, %v, want %q", msg, err, tt.content)
+			}
+			if chunks.String() != tt.chunks {
+				t.Errorf("chunks = %q, want %q", chunks.String(), tt.chunks)
+			}
+
+			var attempts []string
+			for _, attempt := range trace.Attempts {
+				if attempt.Class != "" {
+					attempts = append(attempts, attempt.Provider+":"+string(attempt.Class))
+				} else {
+					attempts = append(attempts, attempt.Provider)
+				}
+			}
+			if strings.Join(attempts, ",") != strings.Join(tt.attempts, ",") {
+				t.Errorf("attempts = %v, want %v", attempts, tt.attempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+)
+
+// ==========================
+// 错误分类
+// ==========================
+
+// ErrorClass 表示 LLM 请求失败的类别，用于决定是否切换到备用提供者
+type ErrorClass string
+
+const (
+	ErrorOverloaded ErrorClass = "overloaded" // 模型过载（overloaded_error、503）
+	ErrorRateLimit  ErrorClass = "rate_limit" // 触发限流（429）
+	ErrorTimeout    ErrorClass = "timeout"    // 请求超时
+	ErrorConnection ErrorClass = "connection" // 无法连接到服务
+	ErrorServer     ErrorClass = "server"     // 服务端内部错误（5xx）
+	ErrorCanceled   ErrorClass = "canceled"   // 请求被调用方取消
+	ErrorOther      ErrorClass = "other"      // 其他错误（参数错误、鉴权失败等）
+)
+
+// DefaultFallbackClasses 未配置时，发生这些类别的错误会切换到备用提供者
+var DefaultFallbackClasses = []ErrorClass{
+	ErrorOverloaded,
+	ErrorRateLimit,
+	ErrorTimeout,
+	ErrorConnection,
+	ErrorServer,
+}
+
+// ClassifyError 根据错误内容判断错误类别
+// 各提供者返回的错误类型不统一，这里和重试机制一样通过错误信息进行匹配
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrorCanceled
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorTimeout
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return ErrorConnection
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorConnection
+	}
+
+	// 限流先于过载判断：OpenAI 提供者的 429 错误信息中也包含 overloaded_error
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "rate_limit"),
+		strings.Contains(msg, "rate limit"),
+		strings.Contains(msg, "status 429"),
+		strings.Contains(msg, "too many requests"):
+		return ErrorRateLimit
+	case strings.Contains(msg, "overloaded"),
+		strings.Contains(msg, "status 503"),
+		strings.Contains(msg, "status 529"),
+		strings.Contains(msg, "service unavailable"):
+		return ErrorOverloaded
+	case strings.Contains(msg, "timeout"),
+		strings.Contains(msg, "deadline exceeded"):
+		return ErrorTimeout
+	case strings.Contains(msg, "connection refused"),
+		strings.Contains(msg, "connection reset"),
+		strings.Contains(msg, "no such host"):
+		return ErrorConnection
+	case strings.Contains(msg, "status 500"),
+		strings.Contains(msg, "status 502"),
+		strings.Contains(msg, "status 504"),
+		strings.Contains(msg, "api_error"),
+		strings.Contains(msg, "bad gateway"),
+		strings.Contains(msg, "internal server error"):
+		return ErrorServer
+	}
+	return ErrorOther
+}
+
+// ParseErrorClasses 将配置中的字符串转换为错误类别，为空时返回默认值
+func ParseErrorClasses(names []string) []ErrorClass {
+	if len(names) == 0 {
+		return DefaultFallbackClasses
+	}
+	classes := make([]ErrorClass, 0, len(names))
+	for _, name := range names {
+		classes = append(classes, ErrorClass(strings.TrimSpace(name)))
+	}
+	return classes
+}
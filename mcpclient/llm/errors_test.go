@@ -0,0 +1,40 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{name: "nil", err: nil, want: ""},
+		{name: "canceled", err: fmt.Errorf("请求失败: %w", context.Canceled), want: ErrorCanceled},
+		{name: "deadline", err: context.DeadlineExceeded, want: ErrorTimeout},
+		{name: "dial", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: ErrorConnection},
+		{name: "eof", err: fmt.Errorf("openai: 读取流式响应失败: %w", io.EOF), want: ErrorConnection},
+		{name: "unexpected eof", err: fmt.Errorf("anthropic: 读取流式响应失败: %w", io.ErrUnexpectedEOF), want: ErrorConnection},
+		{name: "openai 429", err: errors.New("openai: overloaded_error (status 429): rate_limit_exceeded: slow down"), want: ErrorRateLimit},
+		{name: "openai 503", err: errors.New("openai: overloaded_error (status 503): unavailable"), want: ErrorOverloaded},
+		{name: "anthropic rate limit", err: errors.New("anthropic: rate_limit_error: too many tokens"), want: ErrorRateLimit},
+		{name: "anthropic overloaded", err: errors.New("anthropic: overloaded_error: Overloaded"), want: ErrorOverloaded},
+		{name: "timeout message", err: errors.New("ollama: i/o timeout"), want: ErrorTimeout},
+		{name: "server", err: errors.New("openai: 请求失败 (status 500): boom"), want: ErrorServer},
+		{name: "word containing eof is not a connection error", err: errors.New("openai: 请求失败 (status 400): thereof invalid"), want: ErrorOther},
+		{name: "other", err: errors.New("openai: 请求失败 (status 401): invalid api key"), want: ErrorOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.err); got != tt.want {
+				t.Errorf("ClassifyError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -111,3 +111,37 @@ func (t *HistoryToolCall) GetArguments() map[string]interface{} {
 	}
 	return args
 }
+
+// GetResultText 返回工具结果块中的文本内容
+// Content 可能是 []mcp.Content、[]ContentBlock 或者反序列化后得到的通用结构，
+// 这里统一转换为 JSON 后提取其中所有 text 字段
+func (b *ContentBlock) GetResultText() string {
+	if b.Text != "" {
+		return b.Text
+	}
+	switch v := b.Content.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+
+	data, err := json.Marshal(b.Content)
+	if err != nil {
+		return ""
+	}
+	var items []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return string(data) // 不是内容块数组时直接返回原始 JSON
+	}
+	var texts []string
+	for _, item := range items {
+		if item.Text != "" {
+			texts = append(texts, item.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,232 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"gopkg.in/yaml.v3"
+	"mcpclient/llm"
+)
+
+// ErrScriptExhausted 脚本中的所有轮次都已回放完毕
+var ErrScriptExhausted = errors.New("mock: 脚本已回放完毕")
+
+// Provider 按脚本依次回放模型响应的提供者，用于在没有模型服务的情况下进行测试
+type Provider struct {
+	player  *player     // 回放状态，WithOptions 得到的副本与原提供者共享
+	options llm.Options // 生成参数，只记录在请求中
+}
+
+// player 脚本的回放状态
+type player struct {
+	mu       sync.Mutex
+	script   *Script
+	next     int       // 下一轮的下标
+	requests []Request // 收到的请求记录
+}
+
+// NewProvider 根据脚本创建一个 mock 提供者实例
+func NewProvider(script *Script) *Provider {
+	if script == nil {
+		script = &Script{}
+	}
+	return &Provider{player: &player{script: script}}
+}
+
+// NewProviderFromFile 从 YAML 或 JSON 脚本文件创建 mock 提供者实例
+func NewProviderFromFile(path string) (*Provider, error) {
+	script, err := LoadScript(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewProvider(script), nil
+}
+
+// LoadScript 读取并解析脚本文件（JSON 是 YAML 的子集，因此统一按 YAML 解析）
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("mock: 读取脚本失败: %w", err)
+	}
+	var script Script
+	if err := yaml.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("mock: 解析脚本失败: %w", err)
+	}
+	return &script, nil
+}
+
+// CreateMessage 回放下一轮响应
+func (p *Provider) CreateMessage(
+	ctx context.Context,
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) (llm.Message, error) {
+	turn, err := p.nextTurn(prompt, messages, tools, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := sleep(ctx, turn.delay()); err != nil {
+		return nil, err
+	}
+	if turn.Error != "" {
+		return nil, errors.New(turn.Error)
+	}
+	return newMessage(turn), nil
+}
+
+// CreateMessagestream 回放下一轮响应，文本分片依次写入 contentChan
+func (p *Provider) CreateMessagestream(
+	ctx context.Context,
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+	contentChan chan<- string,
+) (llm.Message, error) {
+	turn, err := p.nextTurn(prompt, messages, tools, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, chunk := range turn.streamChunks() {
+		if err := sleep(ctx, turn.delay()); err != nil {
+			return nil, err
+		}
+		select {
+		case contentChan <- chunk:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if turn.Error != "" {
+		if err := sleep(ctx, turn.delay()); err != nil {
+			return nil, err
+		}
+		return nil, errors.New(turn.Error)
+	}
+	return newMessage(turn), nil
+}
+
+// CreateToolResponse 创建并返回工具响应消息
+func (p *Provider) CreateToolResponse(
+	toolCallID string,
+	content interface{},
+) (llm.Message, error) {
+	contentStr, ok := content.(string)
+	if !ok {
+		bytes, err := json.Marshal(content)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling tool response: %w", err)
+		}
+		contentStr = string(bytes)
+	}
+	return &MockMessage{
+		Role:       "tool",
+		Content:    contentStr,
+		ToolCallID: toolCallID,
+	}, nil
+}
+
+// SupportsTools 返回脚本中声明的工具支持情况，默认为 true
+func (p *Provider) SupportsTools() bool {
+	if p.player.script.Tools == nil {
+		return true
+	}
+	return *p.player.script.Tools
+}
+
+// Name 返回提供者的名称
+func (p *Provider) Name() string {
+	return "mock"
+}
+
+// WithOptions 返回一个使用指定生成参数的提供者副本，副本与原提供者共享回放状态
+func (p *Provider) WithOptions(opts llm.Options) llm.Provider {
+	return &Provider{player: p.player, options: opts}
+}
+
+// Requests 返回到目前为止收到的所有请求
+func (p *Provider) Requests() []Request {
+	p.player.mu.Lock()
+	defer p.player.mu.Unlock()
+	return append([]Request(nil), p.player.requests...)
+}
+
+// Remaining 返回尚未回放的轮次数量
+func (p *Provider) Remaining() int {
+	p.player.mu.Lock()
+	defer p.player.mu.Unlock()
+	return len(p.player.script.Turns) - p.player.next
+}
+
+// nextTurn 记录请求并取出下一轮响应
+func (p *Provider) nextTurn(
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+	stream bool,
+) (Turn, error) {
+	pl := p.player
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	req := Request{
+		Prompt:   prompt,
+		Messages: len(messages),
+		Stream:   stream,
+		Options:  p.options,
+	}
+	for _, tool := range tools {
+		req.Tools = append(req.Tools, tool.Name)
+	}
+	pl.requests = append(pl.requests, req)
+
+	if pl.next >= len(pl.script.Turns) {
+		if !pl.script.Loop || len(pl.script.Turns) == 0 {
+			return Turn{}, ErrScriptExhausted
+		}
+		pl.next = 0
+	}
+	turn := pl.script.Turns[pl.next]
+	pl.next++
+
+	log.Debug("mock provider replaying turn",
+		"turn", pl.next,
+		"stream", stream,
+		"num_tool_calls", len(turn.ToolCalls),
+		"error", turn.Error)
+	return turn, nil
+}
+
+// newMessage 根据脚本中的一轮响应构建消息
+func newMessage(turn Turn) *MockMessage {
+	msg := &MockMessage{
+		Role:    "assistant",
+		Content: turn.content(),
+		Usage:   turn.Usage,
+	}
+	for _, call := range turn.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, NewMockToolCall(call))
+	}
+	return msg
+}
+
+// sleep 等待指定时间，期间响应 ctx 的取消
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
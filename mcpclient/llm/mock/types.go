@@ -0,0 +1,192 @@
+package mock
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"mcpclient/llm"
+)
+
+// ==========================
+// 回放脚本结构
+// ==========================
+
+// Script 描述 mock 提供者需要依次回放的模型响应
+// 脚本可以使用 YAML 或 JSON 编写，例如：
+//
+//	turns:
+//	  - chunks: ["你好", "，我是助手"]
+//	  - tool_calls:
+//	      - name: Demo__hello_world
+//	        arguments: {name: "mcp"}
+//	  - error: overloaded_error
+//	  - text: 工具调用完成
+type Script struct {
+	// Tools 表示是否支持工具调用，默认为 true
+	Tools *bool `yaml:"tools" json:"tools"`
+
+	// Loop 为 true 时，所有轮次回放完毕后从头开始
+	Loop bool `yaml:"loop" json:"loop"`
+
+	// Turns 按顺序回放的响应，每调用一次 CreateMessage 或 CreateMessagestream 消耗一轮
+	Turns []Turn `yaml:"turns" json:"turns"`
+}
+
+// Turn 表示一轮模型响应
+type Turn struct {
+	// Text 完整的文本内容（流式模式下作为一个分片输出）
+	Text string `yaml:"text" json:"text"`
+
+	// Chunks 流式输出的文本分片，非流式模式下会拼接为完整内容
+	Chunks []string `yaml:"chunks" json:"chunks"`
+
+	// ToolCalls 本轮发起的工具调用
+	ToolCalls []ScriptToolCall `yaml:"tool_calls" json:"tool_calls"`
+
+	// Error 不为空时本轮返回该错误，例如 overloaded_error；
+	// 流式模式下先输出 Chunks 中的分片再返回错误，用于模拟输出中途失败
+	Error string `yaml:"error" json:"error"`
+
+	// DelayMs 返回前（流式模式下每个分片前）的等待时间，单位毫秒
+	DelayMs int `yaml:"delay_ms" json:"delay_ms"`
+
+	// Usage 本轮的 token 使用情况
+	Usage Usage `yaml:"usage" json:"usage"`
+}
+
+// ScriptToolCall 脚本中的一次工具调用
+type ScriptToolCall struct {
+	ID        string                 `yaml:"id" json:"id"`
+	Name      string                 `yaml:"name" json:"name"`
+	Arguments map[string]interface{} `yaml:"arguments" json:"arguments"`
+}
+
+// Usage token 使用情况
+type Usage struct {
+	InputTokens  int `yaml:"input_tokens" json:"input_tokens"`
+	OutputTokens int `yaml:"output_tokens" json:"output_tokens"`
+}
+
+// content 返回本轮的完整文本
+func (t Turn) content() string {
+	if len(t.Chunks) > 0 {
+		return strings.Join(t.Chunks, "")
+	}
+	return t.Text
+}
+
+// chunks 返回本轮流式输出的分片
+func (t Turn) chunks() []string {
+	if len(t.Chunks) > 0 {
+		return t.Chunks
+	}
+	if t.Text != "" {
+		return []string{t.Text}
+	}
+	return nil
+}
+
+// streamChunks 返回流式模式下在返回之前输出的分片，出错的轮次只输出 Chunks 中的分片
+func (t Turn) streamChunks() []string {
+	if t.Error != "" {
+		return t.Chunks
+	}
+	return t.chunks()
+}
+
+// delay 返回本轮的等待时间
+func (t Turn) delay() time.Duration {
+	return time.Duration(t.DelayMs) * time.Millisecond
+}
+
+// Request 记录提供者收到的一次请求，便于测试中断言
+type Request struct {
+	Prompt   string      // 请求中的提示词
+	Messages int         // 上下文消息数量
+	Tools    []string    // 可用工具名称
+	Stream   bool        // 是否为流式请求
+	Options  llm.Options // 请求使用的生成参数
+}
+
+// ==========================
+// 适配 llm.Message 接口
+// ==========================
+
+// MockMessage 回放得到的消息
+type MockMessage struct {
+	Role       string          // 消息的角色
+	Content    string          // 消息内容
+	ToolCalls  []*MockToolCall // 工具调用
+	ToolCallID string          // 工具响应对应的调用 ID
+	Usage      Usage           // token 使用情况
+}
+
+// 获取消息的角色
+func (m *MockMessage) GetRole() string {
+	return m.Role
+}
+
+// 获取消息内容（去掉两端的空白字符）
+func (m *MockMessage) GetContent() string {
+	return strings.TrimSpace(m.Content)
+}
+
+// 获取工具调用
+func (m *MockMessage) GetToolCalls() []llm.ToolCall {
+	var calls []llm.ToolCall
+	for _, call := range m.ToolCalls {
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// 获取消息的 token 使用情况
+func (m *MockMessage) GetUsage() (int, int) {
+	return m.Usage.InputTokens, m.Usage.OutputTokens
+}
+
+// 判断消息是否为工具响应
+func (m *MockMessage) IsToolResponse() bool {
+	return m.Role == "tool"
+}
+
+// 获取工具响应的 ID
+func (m *MockMessage) GetToolResponseID() string {
+	return m.ToolCallID
+}
+
+// MockToolCall 回放得到的工具调用
+type MockToolCall struct {
+	id   string                 // 工具调用的 ID
+	name string                 // 工具的名称
+	args map[string]interface{} // 工具调用的参数
+}
+
+// 创建一个新的工具调用，脚本中没有指定 ID 时自动生成
+func NewMockToolCall(call ScriptToolCall) *MockToolCall {
+	id := call.ID
+	if id == "" {
+		id = fmt.Sprintf("tc_%s_%d", call.Name, time.Now().UnixNano())
+	}
+	args := call.Arguments
+	if args == nil {
+		args = make(map[string]interface{})
+	}
+	return &MockToolCall{id: id, name: call.Name, args: args}
+}
+
+// 获取工具调用的名称
+func (t *MockToolCall) GetName() string {
+	return t.name
+}
+
+// 获取工具调用的参数
+func (t *MockToolCall) GetArguments() map[string]interface{} {
+	return t.args
+}
+
+// 获取工具调用的 ID
+func (t *MockToolCall) GetID() string {
+	return t.id
+}
@@ -0,0 +1,105 @@
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	api "github.com/ollama/ollama/api"
+	"mcpclient/llm"
+)
+
+// 单个响应分片的最大长度，与 Ollama 客户端一致
+const maxBufferSize = 512 * 1000
+
+// chatRequest 与 api.ChatRequest 相同，只是工具参数使用完整的 JSON Schema
+// api.ToolFunction 的参数只能表示一层属性（type、description、字符串 enum），
+// 嵌套对象、数组元素、联合类型、默认值和格式都会丢失，因此工具使用自己定义的类型
+type chatRequest struct {
+	*api.ChatRequest
+	Tools []tool `json:"tools,omitempty"` // 覆盖 api.ChatRequest 中的 Tools
+}
+
+// tool Ollama 请求中的工具定义
+type tool struct {
+	Type     string       `json:"type"`
+	Function toolFunction `json:"function"`
+}
+
+type toolFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// convertTools 将工具转换为 Ollama 格式
+func convertTools(tools []llm.Tool) []tool {
+	ollamaTools := make([]tool, len(tools))
+	for i, t := range tools {
+		ollamaTools[i] = tool{
+			Type: "function",
+			Function: toolFunction{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.InputSchema.Map(),
+			},
+		}
+	}
+	return ollamaTools
+}
+
+// chat 发送 /api/chat 请求，fn 在收到每个响应分片时调用
+// 与 api.Client.Chat 的行为一致，区别只在于请求中的工具定义
+func (p *Provider) chat(ctx context.Context, req *api.ChatRequest, tools []llm.Tool, fn api.ChatResponseFunc) error {
+	body, err := json.Marshal(chatRequest{ChatRequest: req, Tools: convertTools(tools)})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, p.host.JoinPath("/api/chat").String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/x-ndjson")
+
+	response, err := p.http.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 0, maxBufferSize), maxBufferSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var errorResponse struct {
+			Error string `json:"error,omitempty"`
+		}
+		if err := json.Unmarshal(line, &errorResponse); err != nil {
+			return fmt.Errorf("unmarshal: %w", err)
+		}
+		if errorResponse.Error != "" {
+			return errors.New(errorResponse.Error)
+		}
+		if response.StatusCode >= http.StatusBadRequest {
+			return api.StatusError{
+				StatusCode: response.StatusCode,
+				Status:     response.Status,
+			}
+		}
+
+		var chunk api.ChatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return err
+		}
+		if err := fn(chunk); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,168 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	api "github.com/ollama/ollama/api"
+	"mcpclient/llm"
+)
+
+// newTestProvider 创建请求发送到 handler 的提供者
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *Provider {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	host, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Provider{host: host, http: server.Client(), model: "test"}
+}
+
+func TestChatToolSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string // MCP 服务器返回的输入 Schema
+		want   string // 请求中 tools[0].function.parameters 的 JSON
+	}{
+		{
+			name:   "object",
+			schema: `{"type":"object","properties":{"city":{"type":"string","description":"城市","enum":["北京","上海"]},"days":{"type":"integer","default":3}},"required":["city"]}`,
+			want:   `{"type":"object","properties":{"city":{"type":"string","description":"城市","enum":["北京","上海"]},"days":{"type":"integer","default":3}},"required":["city"]}`,
+		},
+		{
+			name:   "empty",
+			schema: `{}`,
+			want:   `{"type":"object","properties":{}}`,
+		},
+		{
+			name:   "array of object",
+			schema: `{"type":"object","properties":{"items":{"type":"array","items":{"type":"object","properties":{"id":{"type":"string"},"tags":{"type":"array","items":{"type":"string"}}},"required":["id"]}}}}`,
+			want:   `{"type":"object","properties":{"items":{"type":"array","items":{"type":"object","properties":{"id":{"type":"string"},"tags":{"type":"array","items":{"type":"string"}}},"required":["id"]}}}}`,
+		},
+		{
+			name:   "union type array",
+			schema: `{"type":"object","properties":{"limit":{"type":["integer","null"]}}}`,
+			want:   `{"type":"object","properties":{"limit":{"type":["integer","null"]}}}`,
+		},
+		{
+			name:   "union anyOf",
+			schema: `{"type":"object","properties":{"id":{"anyOf":[{"type":"string","format":"uuid"},{"type":"integer","minimum":1}]}}}`,
+			want:   `{"type":"object","properties":{"id":{"anyOf":[{"type":"string","format":"uuid"},{"type":"integer","minimum":1}]}}}`,
+		},
+		{
+			name:   "$defs and $ref",
+			schema: `{"type":"object","properties":{"owner":{"$ref":"#/$defs/person"}},"$defs":{"person":{"type":"object","properties":{"name":{"type":"string"}}}}}`,
+			want:   `{"type":"object","properties":{"owner":{"$ref":"#/$defs/person"}},"$defs":{"person":{"type":"object","properties":{"name":{"type":"string"}}}}}`,
+		},
+		{
+			name:   "additionalProperties false",
+			schema: `{"type":"object","properties":{"name":{"type":"string"}},"additionalProperties":false}`,
+			want:   `{"type":"object","properties":{"name":{"type":"string"}},"additionalProperties":false}`,
+		},
+		{
+			name:   "additionalProperties schema",
+			schema: `{"type":"object","additionalProperties":{"type":"number"}}`,
+			want:   `{"type":"object","properties":{},"additionalProperties":{"type":"number"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var schema llm.Schema
+			if err := json.Unmarshal([]byte(tt.schema), &schema); err != nil {
+				t.Fatal(err)
+			}
+
+			var body []byte
+			p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				body, _ = io.ReadAll(r.Body)
+				io.WriteString(w, `{"model":"test","message":{"role":"assistant","content":"ok"},"done":true}`+"\n")
+			})
+			err := p.chat(context.Background(), &api.ChatRequest{Model: "test"}, []llm.Tool{{
+				Name:        "Demo__tool",
+				Description: "测试工具",
+				InputSchema: schema,
+			}}, func(api.ChatResponse) error { return nil })
+			if err != nil {
+				t.Fatalf("chat() error = %v", err)
+			}
+
+			var request struct {
+				Tools []struct {
+					Type     string `json:"type"`
+					Function struct {
+						Name       string      `json:"name"`
+						Parameters interface{} `json:"parameters"`
+					} `json:"function"`
+				} `json:"tools"`
+			}
+			if err := json.Unmarshal(body, &request); err != nil {
+				t.Fatalf("unmarshal request: %v", err)
+			}
+			if len(request.Tools) != 1 || request.Tools[0].Type != "function" || request.Tools[0].Function.Name != "Demo__tool" {
+				t.Fatalf("tools = %s", body)
+			}
+			var want interface{}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if got := request.Tools[0].Function.Parameters; !reflect.DeepEqual(got, want) {
+				gotJSON, _ := json.Marshal(got)
+				t.Errorf("parameters = %s, want %s", gotJSON, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		io.WriteString(w, `{"model":"test","message":{"role":"assistant","content":"你"},"done":false}`+"\n")
+		io.WriteString(w, `{"model":"test","message":{"role":"assistant","content":"好"},"done":true}`+"\n")
+	})
+	var content string
+	err := p.chat(context.Background(), &api.ChatRequest{Model: "test"}, nil, func(response api.ChatResponse) error {
+		content += response.Message.Content
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("chat() error = %v", err)
+	}
+	if content != "你好" {
+		t.Errorf("content = %q, want %q", content, "你好")
+	}
+}
+
+func TestChatError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{name: "error line", status: http.StatusOK, body: `{"error":"model not found"}`, want: "model not found"},
+		{name: "status", status: http.StatusInternalServerError, body: `{}`, want: "500 Internal Server Error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body+"\n")
+			})
+			err := p.chat(context.Background(), &api.ChatRequest{Model: "test"}, nil, func(api.ChatResponse) error { return nil })
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("chat() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/charmbracelet/log"
 	api "github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/envconfig"
 	"mcpclient/llm"
 	"mcpclient/llm/history"
 )
@@ -19,8 +22,11 @@ func boolPtr(b bool) *bool {
 
 // Provider 实现了 Ollama 提供者接口
 type Provider struct {
-	client *api.Client // 与 Ollama API 的客户端连接
-	model  string      // 使用的模型名称
+	client  *api.Client  // 与 Ollama API 的客户端连接
+	host    *url.URL     // Ollama 服务的地址，对话请求由 chat 直接发送
+	http    *http.Client // 发送对话请求的 HTTP 客户端
+	model   string       // 使用的模型名称
+	options llm.Options  // 生成参数
 }
 
 // NewProvider 创建一个新的 Ollama 提供者实例
@@ -31,8 +37,10 @@ func NewProvider(model string) (*Provider, error) {
 		return nil, err
 	}
 	return &Provider{
-		client: client, // 初始化客户端
-		model:  model,  // 设置模型
+		client: client,             // 初始化客户端
+		host:   envconfig.Host(),   // 与 api.ClientFromEnvironment 使用相同的地址
+		http:   http.DefaultClient, // 与 api.ClientFromEnvironment 使用相同的 HTTP 客户端
+		model:  model,              // 设置模型
 	}, nil
 }
 func (p *Provider) CreateMessagestream(
@@ -52,25 +60,7 @@ func (p *Provider) CreateMessagestream(
 	ollamaMessages := make([]api.Message, 0, len(messages)+1)
 	for _, msg := range messages {
 		if msg.IsToolResponse() {
-			var content string
-			if historyMsg, ok := msg.(*history.HistoryMessage); ok {
-				for _, block := range historyMsg.Content {
-					if block.Type == "tool_result" {
-						content = block.Text
-						break
-					}
-				}
-			}
-			if content == "" {
-				content = msg.GetContent()
-			}
-			if content == "" {
-				continue
-			}
-			ollamaMessages = append(ollamaMessages, api.Message{
-				Role:    "tool",
-				Content: content,
-			})
+			ollamaMessages = append(ollamaMessages, convertToolResults(msg)...)
 			continue
 		}
 		if msg.GetContent() == "" && len(msg.GetToolCalls()) == 0 {
@@ -103,67 +93,49 @@ func (p *Provider) CreateMessagestream(
 		})
 	}
 
-	// 将工具转换为 Ollama 格式
-	ollamaTools := make([]api.Tool, len(tools))
-	for i, tool := range tools {
-		ollamaTools[i] = api.Tool{
-			Type: "function",
-			Function: api.ToolFunction{
-				Name:        tool.Name,
-				Description: tool.Description,
-				Parameters: struct {
-					Type       string   `json:"type"`
-					Required   []string `json:"required"`
-					Properties map[string]struct {
-						Type        string   `json:"type"`
-						Description string   `json:"description"`
-						Enum        []string `json:"enum,omitempty"`
-					} `json:"properties"`
-				}{
-					Type:       tool.InputSchema.Type,
-					Required:   tool.InputSchema.Required,
-					Properties: convertProperties(tool.InputSchema.Properties),
-				},
-			},
-		}
-	}
-
 	// 日志记录请求发送信息
 	log.Debug("sending messages to Ollama",
 		"messages", ollamaMessages,
 		"num_tools", len(tools))
 
-	// 流式处理响应
+	// 流式处理响应，文本分片实时写入通道，工具调用可能分散在多个分片中，需要累积
 	var sb strings.Builder
 	var role string
+	var toolCalls []api.ToolCall
 	var response api.Message
 
-	err := p.client.Chat(ctx, &api.ChatRequest{
+	err := p.chat(ctx, &api.ChatRequest{
 		Model:    p.model,
 		Messages: ollamaMessages,
-		Tools:    ollamaTools,
 		Stream:   boolPtr(true), // 启用流式传输
-	}, func(r api.ChatResponse) error {
+		Options:  convertOptions(p.options),
+	}, tools, func(r api.ChatResponse) error {
+		if role == "" { // 仅从第一个分片获取角色
+			role = r.Message.Role
+		}
 		if r.Message.Content != "" {
-			if role == "" { // 仅从第一个分片获取角色
-				role = r.Message.Role
-			}
 			// 将分片内容写入通道
-			contentChan <- r.Message.Content
+			select {
+			case contentChan <- r.Message.Content:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
 			sb.WriteString(r.Message.Content) // 累积内容以返回完整消息
 		}
+		if len(r.Message.ToolCalls) > 0 {
+			toolCalls = append(toolCalls, r.Message.ToolCalls...)
+		}
 		if r.Done {
 			response = api.Message{
-				Role:    role,
-				Content: sb.String(),
+				Role:      role,
+				Content:   sb.String(),
+				ToolCalls: toolCalls,
 			}
-			close(contentChan) // 流结束时关闭通道
 		}
 		return nil
 	})
 
 	if err != nil {
-		close(contentChan) // 出现错误时关闭通道
 		return nil, err
 	}
 
@@ -190,35 +162,8 @@ func (p *Provider) CreateMessage(
 	for _, msg := range messages {
 		// 如果是工具响应消息
 		if msg.IsToolResponse() {
-			var content string
-
-			// 如果消息是历史记录格式
-			if historyMsg, ok := msg.(*history.HistoryMessage); ok {
-				for _, block := range historyMsg.Content {
-					// 如果是工具结果类型的内容块
-					if block.Type == "tool_result" {
-						content = block.Text
-						break
-					}
-				}
-			}
-
-			// 如果没有找到内容，再尝试提取标准内容
-			if content == "" {
-				content = msg.GetContent()
-			}
-
-			// 如果没有内容，跳过该消息
-			if content == "" {
-				continue
-			}
-
 			// 将工具响应消息添加到 Ollama 消息中
-			ollamaMsg := api.Message{
-				Role:    "tool", // 角色设置为 "tool"
-				Content: content,
-			}
-			ollamaMessages = append(ollamaMessages, ollamaMsg)
+			ollamaMessages = append(ollamaMessages, convertToolResults(msg)...)
 			continue
 		}
 
@@ -264,31 +209,6 @@ func (p *Provider) CreateMessage(
 		})
 	}
 
-	// 将工具转换为 Ollama 格式
-	ollamaTools := make([]api.Tool, len(tools))
-	for i, tool := range tools {
-		ollamaTools[i] = api.Tool{
-			Type: "function",
-			Function: api.ToolFunction{
-				Name:        tool.Name,
-				Description: tool.Description,
-				Parameters: struct {
-					Type       string   `json:"type"`
-					Required   []string `json:"required"`
-					Properties map[string]struct {
-						Type        string   `json:"type"`
-						Description string   `json:"description"`
-						Enum        []string `json:"enum,omitempty"`
-					} `json:"properties"`
-				}{
-					Type:       tool.InputSchema.Type,
-					Required:   tool.InputSchema.Required,
-					Properties: convertProperties(tool.InputSchema.Properties),
-				},
-			},
-		}
-	}
-
 	// 日志记录请求发送信息
 	log.Debug("sending messages to Ollama",
 		"messages", ollamaMessages,
@@ -296,12 +216,12 @@ func (p *Provider) CreateMessage(
 
 	// 向 Ollama API 发送请求并获取响应
 	var response api.Message
-	err := p.client.Chat(ctx, &api.ChatRequest{
+	err := p.chat(ctx, &api.ChatRequest{
 		Model:    p.model,
 		Messages: ollamaMessages,
-		Tools:    ollamaTools,
 		Stream:   boolPtr(false),
-	}, func(r api.ChatResponse) error {
+		Options:  convertOptions(p.options),
+	}, tools, func(r api.ChatResponse) error {
 		// 获取消息响应
 		if r.Done {
 			response = r.Message
@@ -335,6 +255,13 @@ func (p *Provider) Name() string {
 	return "ollama"
 }
 
+// WithOptions 返回一个使用指定生成参数的提供者副本
+func (p *Provider) WithOptions(opts llm.Options) llm.Provider {
+	cp := *p
+	cp.options = opts
+	return &cp
+}
+
 // CreateToolResponse 创建并返回工具响应消息
 func (p *Provider) CreateToolResponse(
 	toolCallID string,
@@ -387,51 +314,61 @@ func (p *Provider) CreateToolResponse(
 	return msg, nil
 }
 
-// convertProperties 将工具的属性转换为 Ollama 格式
-func convertProperties(props map[string]interface{}) map[string]struct {
-	Type        string   `json:"type"`
-	Description string   `json:"description"`
-	Enum        []string `json:"enum,omitempty"`
-} {
-	result := make(map[string]struct {
-		Type        string   `json:"type"`
-		Description string   `json:"description"`
-		Enum        []string `json:"enum,omitempty"`
-	})
+// convertOptions 将生成参数转换为 Ollama 的 ChatRequest.Options
+func convertOptions(opts llm.Options) map[string]interface{} {
+	options := make(map[string]interface{})
+	if opts.Temperature != nil {
+		options["temperature"] = *opts.Temperature
+	}
+	if opts.TopP != nil {
+		options["top_p"] = *opts.TopP
+	}
+	if opts.MaxTokens != nil {
+		options["num_predict"] = *opts.MaxTokens
+	}
+	if len(opts.Stop) > 0 {
+		options["stop"] = opts.Stop
+	}
+	if opts.Seed != nil {
+		options["seed"] = *opts.Seed
+	}
+	if opts.NumCtx != nil {
+		options["num_ctx"] = *opts.NumCtx
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return options
+}
 
-	// 遍历属性并将其转换为 Ollama 格式
-	for name, prop := range props {
-		if propMap, ok := prop.(map[string]interface{}); ok {
-			prop := struct {
-				Type        string   `json:"type"`
-				Description string   `json:"description"`
-				Enum        []string `json:"enum,omitempty"`
-			}{
-				Type:        getString(propMap, "type"),
-				Description: getString(propMap, "description"),
+// convertToolResults 将工具响应消息转换为 Ollama 格式，每个工具结果对应一条 tool 消息
+func convertToolResults(msg llm.Message) []api.Message {
+	var results []api.Message
+	// 如果消息是历史记录格式，逐个提取工具结果块中的文本
+	if historyMsg, ok := msg.(*history.HistoryMessage); ok {
+		for i := range historyMsg.Content {
+			block := &historyMsg.Content[i]
+			if block.Type != "tool_result" {
+				continue
 			}
-
-			// 如果属性有枚举值，提取枚举值
-			if enumRaw, ok := propMap["enum"].([]interface{}); ok {
-				for _, e := range enumRaw {
-					if str, ok := e.(string); ok {
-						prop.Enum = append(prop.Enum, str)
-					}
-				}
+			if content := block.GetResultText(); content != "" {
+				results = append(results, api.Message{
+					Role:    "tool",
+					Content: content,
+				})
 			}
-
-			// 将转换后的属性添加到结果中
-			result[name] = prop
+		}
+		if len(results) > 0 {
+			return results
 		}
 	}
 
-	return result
-}
-
-// getString 从 map 中安全地获取字符串值
-func getString(m map[string]interface{}, key string) string {
-	if v, ok := m[key].(string); ok {
-		return v
+	// 如果没有找到内容，再尝试提取标准内容
+	if content := msg.GetContent(); content != "" {
+		results = append(results, api.Message{
+			Role:    "tool", // 角色设置为 "tool"
+			Content: content,
+		})
 	}
-	return ""
+	return results
 }
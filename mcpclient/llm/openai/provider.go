@@ -0,0 +1,424 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"mcpclient/llm"
+	"mcpclient/llm/history"
+)
+
+// 未配置 base_url 时使用的默认地址
+const defaultBaseURL = "https://api.openai.com/v1"
+
+// Provider 实现了兼容 OpenAI Chat Completions 协议的提供者接口
+// 可以对接 OpenAI、vLLM、llama.cpp server、LM Studio 等服务
+type Provider struct {
+	client  *http.Client // HTTP 客户端
+	baseURL string       // 服务地址，例如 http://localhost:8000/v1
+	apiKey  string       // API Key，本地服务可以为空
+	model   string       // 使用的模型名称
+	options llm.Options  // 生成参数（num_ctx 不适用于该协议）
+}
+
+// NewProvider 创建一个新的 OpenAI 兼容提供者实例
+// baseURL 为空时使用官方地址，apiKey 为空时从环境变量 OPENAI_API_KEY 读取
+func NewProvider(baseURL, apiKey, model string) (*Provider, error) {
+	if model == "" {
+		return nil, fmt.Errorf("openai: 模型名称不能为空")
+	}
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	if apiKey == "" {
+		apiKey = os.Getenv("OPENAI_API_KEY")
+	}
+	return &Provider{
+		client:  http.DefaultClient,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+	}, nil
+}
+
+// CreateMessage 创建并返回一条消息
+func (p *Provider) CreateMessage(
+	ctx context.Context,
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) (llm.Message, error) {
+	log.Debug("creating message",
+		"prompt", prompt,
+		"num_messages", len(messages),
+		"num_tools", len(tools))
+
+	req := p.buildRequest(prompt, messages, tools)
+
+	resp, err := p.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var chatResp chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return nil, fmt.Errorf("openai: 解析响应失败: %w", err)
+	}
+	if len(chatResp.Choices) == 0 {
+		return nil, fmt.Errorf("openai: 响应中没有 choices")
+	}
+
+	msg := &OpenAIMessage{Message: chatResp.Choices[0].Message}
+	if msg.Message.Role == "" {
+		msg.Message.Role = "assistant"
+	}
+	if chatResp.Usage != nil {
+		msg.Usage = *chatResp.Usage
+	}
+	return msg, nil
+}
+
+// CreateMessagestream 以流式方式创建消息，文本分片会写入 contentChan
+// 工具调用的分片按 index 拼接，在流结束后随完整消息一起返回
+func (p *Provider) CreateMessagestream(
+	ctx context.Context,
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+	contentChan chan<- string,
+) (llm.Message, error) {
+	log.Debug("creating message stream",
+		"prompt", prompt,
+		"num_messages", len(messages),
+		"num_tools", len(tools))
+
+	req := p.buildRequest(prompt, messages, tools)
+	req.Stream = true
+	req.StreamOptions = &streamOptions{IncludeUsage: true}
+
+	resp, err := p.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var sb strings.Builder
+	var usage chatUsage
+	role := "assistant"
+	calls := make(map[int]*chatToolCall) // 按 index 累积工具调用分片
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk chatResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("openai: 解析流式分片失败: %w", err)
+		}
+		if chunk.Usage != nil {
+			usage = *chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			delta := choice.Delta
+			if delta.Role != "" {
+				role = delta.Role
+			}
+			if delta.Content != "" {
+				// 调用方不再读取时（例如客户端断开）通过 ctx 退出，避免阻塞
+				select {
+				case contentChan <- delta.Content:
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+				sb.WriteString(delta.Content)
+			}
+			for i, part := range delta.ToolCalls {
+				index := i
+				if part.Index != nil {
+					index = *part.Index
+				}
+				call, ok := calls[index]
+				if !ok {
+					call = &chatToolCall{Type: "function"}
+					calls[index] = call
+				}
+				if part.ID != "" {
+					call.ID = part.ID
+				}
+				call.Function.Name += part.Function.Name
+				call.Function.Arguments += part.Function.Arguments
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("openai: 读取流式响应失败: %w", err)
+	}
+
+	// 按 index 顺序整理工具调用
+	indexes := make([]int, 0, len(calls))
+	for index := range calls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	response := chatMessage{
+		Role:    role,
+		Content: sb.String(),
+	}
+	for _, index := range indexes {
+		response.ToolCalls = append(response.ToolCalls, *calls[index])
+	}
+
+	return &OpenAIMessage{Message: response, Usage: usage}, nil
+}
+
+// SupportsTools Chat Completions 协议本身支持函数调用
+func (p *Provider) SupportsTools() bool {
+	return true
+}
+
+// Name 返回提供者的名称
+func (p *Provider) Name() string {
+	return "openai"
+}
+
+// WithOptions 返回一个使用指定生成参数的提供者副本
+func (p *Provider) WithOptions(opts llm.Options) llm.Provider {
+	cp := *p
+	cp.options = opts
+	return &cp
+}
+
+// CreateToolResponse 创建并返回工具响应消息
+func (p *Provider) CreateToolResponse(
+	toolCallID string,
+	content interface{},
+) (llm.Message, error) {
+	log.Debug("creating tool response",
+		"tool_call_id", toolCallID,
+		"content_type", fmt.Sprintf("%T", content))
+
+	contentStr := ""
+	switch v := content.(type) {
+	case string:
+		contentStr = v
+	default:
+		bytes, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling tool response: %w", err)
+		}
+		contentStr = string(bytes)
+	}
+
+	return &OpenAIMessage{
+		Message: chatMessage{
+			Role:       "tool",
+			Content:    contentStr,
+			ToolCallID: toolCallID,
+		},
+	}, nil
+}
+
+// buildRequest 将消息和工具转换为 Chat Completions 请求
+func (p *Provider) buildRequest(
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) *chatRequest {
+	chatMessages := convertMessages(messages)
+	if prompt != "" {
+		chatMessages = append(chatMessages, chatMessage{
+			Role:    "user",
+			Content: prompt,
+		})
+	}
+
+	req := &chatRequest{
+		Model:       p.model,
+		Messages:    chatMessages,
+		Temperature: p.options.Temperature,
+		TopP:        p.options.TopP,
+		MaxTokens:   p.options.MaxTokens,
+		Stop:        p.options.Stop,
+		Seed:        p.options.Seed,
+	}
+	for _, tool := range tools {
+		req.Tools = append(req.Tools, chatTool{
+			Type: "function",
+			Function: chatFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.InputSchema.Map(),
+			},
+		})
+	}
+	return req
+}
+
+// post 发送请求，非 2xx 响应会被转换为错误
+func (p *Provider) post(ctx context.Context, body *chatRequest) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("openai: 序列化请求失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		p.baseURL+"/chat/completions",
+		bytes.NewReader(data),
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	if body.Stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	return nil, parseError(resp)
+}
+
+// parseError 将错误响应转换为 error
+// 429/503 视为过载，错误信息中包含 overloaded_error 以便上层的重试机制识别
+func parseError(resp *http.Response) error {
+	raw, _ := io.ReadAll(resp.Body)
+	message := strings.TrimSpace(string(raw))
+
+	var errResp errorResponse
+	if err := json.Unmarshal(raw, &errResp); err == nil && errResp.Error.Message != "" {
+		message = errResp.Error.Message
+		if errResp.Error.Type != "" {
+			message = errResp.Error.Type + ": " + message
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return fmt.Errorf("openai: overloaded_error (status %d): %s", resp.StatusCode, message)
+	default:
+		return fmt.Errorf("openai: 请求失败 (status %d): %s", resp.StatusCode, message)
+	}
+}
+
+// convertMessages 将 llm.Message 列表转换为 Chat Completions 消息
+func convertMessages(messages []llm.Message) []chatMessage {
+	result := make([]chatMessage, 0, len(messages))
+	for _, msg := range messages {
+		// 历史记录格式的消息按内容块逐一转换
+		if historyMsg, ok := msg.(*history.HistoryMessage); ok {
+			result = append(result, convertHistoryMessage(historyMsg)...)
+			continue
+		}
+
+		if msg.IsToolResponse() {
+			result = append(result, chatMessage{
+				Role:       "tool",
+				Content:    msg.GetContent(),
+				ToolCallID: msg.GetToolResponseID(),
+			})
+			continue
+		}
+
+		chatMsg := chatMessage{
+			Role:    msg.GetRole(),
+			Content: msg.GetContent(),
+		}
+		for _, call := range msg.GetToolCalls() {
+			chatMsg.ToolCalls = append(chatMsg.ToolCalls, newChatToolCall(
+				call.GetID(), call.GetName(), call.GetArguments()))
+		}
+		if chatMsg.Content == "" && len(chatMsg.ToolCalls) == 0 {
+			continue
+		}
+		result = append(result, chatMsg)
+	}
+	return result
+}
+
+// convertHistoryMessage 转换一条历史消息
+// tool_result 块会拆分为独立的 tool 消息，tool_use 块转换为助手消息的 tool_calls
+func convertHistoryMessage(msg *history.HistoryMessage) []chatMessage {
+	var result []chatMessage
+	chatMsg := chatMessage{Role: msg.Role}
+	var texts []string
+
+	for _, block := range msg.Content {
+		switch block.Type {
+		case "text":
+			if block.Text != "" {
+				texts = append(texts, block.Text)
+			}
+		case "tool_use":
+			args := string(block.Input)
+			if args == "" {
+				args = "{}"
+			}
+			chatMsg.ToolCalls = append(chatMsg.ToolCalls, chatToolCall{
+				ID:   block.ID,
+				Type: "function",
+				Function: chatCallFunction{
+					Name:      block.Name,
+					Arguments: args,
+				},
+			})
+		case "tool_result":
+			result = append(result, chatMessage{
+				Role:       "tool",
+				Content:    block.GetResultText(),
+				ToolCallID: block.ToolUseID,
+			})
+		}
+	}
+
+	chatMsg.Content = strings.Join(texts, "\n")
+	if chatMsg.Content != "" || len(chatMsg.ToolCalls) > 0 {
+		result = append(result, chatMsg)
+	}
+	return result
+}
+
+// newChatToolCall 根据工具调用信息构建 Chat Completions 的 tool_call
+func newChatToolCall(id, name string, args map[string]interface{}) chatToolCall {
+	data, err := json.Marshal(args)
+	if err != nil || args == nil {
+		data = []byte("{}")
+	}
+	return chatToolCall{
+		ID:   id,
+		Type: "function",
+		Function: chatCallFunction{
+			Name:      name,
+			Arguments: string(data),
+		},
+	}
+}
@@ -0,0 +1,241 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"mcpclient/llm"
+	"mcpclient/llm/history"
+)
+
+// newTestProvider 创建请求发送到 handler 的提供者，handler 收到的请求体写入 body
+func newTestProvider(t *testing.T, body *chatRequest, handler func(w http.ResponseWriter)) *Provider {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer sk-test" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if body != nil {
+			if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+				t.Errorf("decode request: %v", err)
+			}
+		}
+		handler(w)
+	}))
+	t.Cleanup(server.Close)
+	p, err := NewProvider(server.URL+"/v1/", "sk-test", "gpt-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+var weatherTool = llm.Tool{
+	Name:        "Weather__forecast",
+	Description: "天气预报",
+	InputSchema: llm.Schema{
+		Type: "object",
+		Properties: map[string]interface{}{
+			"city": map[string]interface{}{"$ref": "#/$defs/city"},
+		},
+		Required:             []string{"city"},
+		AdditionalProperties: false,
+		Defs: map[string]interface{}{
+			"city": map[string]interface{}{"type": "string"},
+		},
+	},
+}
+
+func TestCreateMessagestream(t *testing.T) {
+	// 文本分片之后，一个工具调用的名称和参数分散在多个分片中，最后一个分片只有 usage
+	stream := []string{
+		`{"choices":[{"index":0,"delta":{"role":"assistant","content":"让我"}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":"查一下"}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"Weather__forecast","arguments":""}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"北京\"}"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":7,"total_tokens":19}}`,
+	}
+	var request chatRequest
+	p := newTestProvider(t, &request, func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, data := range stream {
+			io.WriteString(w, "data: "+data+"\n\n")
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	})
+
+	messages := []llm.Message{
+		&history.HistoryMessage{Role: "user", Content: []history.ContentBlock{{Type: "text", Text: "北京天气"}}},
+		&history.HistoryMessage{Role: "assistant", Content: []history.ContentBlock{
+			{Type: "tool_use", ID: "call_0", Name: "Weather__forecast", Input: json.RawMessage(`{"city":"上海"}`)},
+		}},
+		&history.HistoryMessage{Role: "user", Content: []history.ContentBlock{
+			{Type: "tool_result", ToolUseID: "call_0", Text: "晴"},
+		}},
+	}
+	contentChan := make(chan string, 10)
+	message, err := p.CreateMessagestream(context.Background(), "", messages, []llm.Tool{weatherTool}, contentChan)
+	if err != nil {
+		t.Fatalf("CreateMessagestream() error = %v", err)
+	}
+	close(contentChan)
+
+	// 请求
+	if !request.Stream || request.StreamOptions == nil || !request.StreamOptions.IncludeUsage {
+		t.Errorf("stream = %v, stream_options = %+v", request.Stream, request.StreamOptions)
+	}
+	wantMessages := []chatMessage{
+		{Role: "user", Content: "北京天气"},
+		{Role: "assistant", ToolCalls: []chatToolCall{{ID: "call_0", Type: "function", Function: chatCallFunction{Name: "Weather__forecast", Arguments: `{"city":"上海"}`}}}},
+		{Role: "tool", Content: "晴", ToolCallID: "call_0"},
+	}
+	if !reflect.DeepEqual(request.Messages, wantMessages) {
+		t.Errorf("messages = %+v, want %+v", request.Messages, wantMessages)
+	}
+	if len(request.Tools) != 1 {
+		t.Fatalf("tools = %+v", request.Tools)
+	}
+	parameters, _ := json.Marshal(request.Tools[0].Function.Parameters)
+	wantParameters := `{"$defs":{"city":{"type":"string"}},"additionalProperties":false,"properties":{"city":{"$ref":"#/$defs/city"}},"required":["city"],"type":"object"}`
+	if string(parameters) != wantParameters {
+		t.Errorf("parameters = %s, want %s", parameters, wantParameters)
+	}
+
+	// 响应
+	var chunks []string
+	for chunk := range contentChan {
+		chunks = append(chunks, chunk)
+	}
+	if strings.Join(chunks, "|") != "让我|查一下" {
+		t.Errorf("chunks = %q", chunks)
+	}
+	if message.GetContent() != "让我查一下" || message.GetRole() != "assistant" {
+		t.Errorf("message = %q (%s)", message.GetContent(), message.GetRole())
+	}
+	toolCalls := message.GetToolCalls()
+	if len(toolCalls) != 1 {
+		t.Fatalf("tool calls = %d, want 1", len(toolCalls))
+	}
+	if toolCalls[0].GetID() != "call_1" || toolCalls[0].GetName() != "Weather__forecast" ||
+		!reflect.DeepEqual(toolCalls[0].GetArguments(), map[string]interface{}{"city": "北京"}) {
+		t.Errorf("tool call = %s %s %v", toolCalls[0].GetID(), toolCalls[0].GetName(), toolCalls[0].GetArguments())
+	}
+	if input, output := message.GetUsage(); input != 12 || output != 7 {
+		t.Errorf("usage = %d, %d", input, output)
+	}
+}
+
+func TestCreateMessage(t *testing.T) {
+	temperature := 0.2
+	var request chatRequest
+	p := newTestProvider(t, &request, func(w http.ResponseWriter) {
+		io.WriteString(w, `{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"","tool_calls":[
+			{"id":"call_a","type":"function","function":{"name":"Weather__forecast","arguments":"{\"city\":\"北京\"}"}},
+			{"id":"call_b","type":"function","function":{"name":"Weather__forecast","arguments":"{\"city\":\"上海\"}"}}
+		]},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":5,"completion_tokens":3,"total_tokens":8}}`)
+	}).WithOptions(llm.Options{Temperature: &temperature})
+
+	message, err := p.CreateMessage(context.Background(), "北京和上海的天气", nil, []llm.Tool{weatherTool})
+	if err != nil {
+		t.Fatalf("CreateMessage() error = %v", err)
+	}
+	if request.Stream || request.Temperature == nil || *request.Temperature != temperature {
+		t.Errorf("stream = %v, temperature = %v", request.Stream, request.Temperature)
+	}
+	if len(request.Messages) != 1 || request.Messages[0].Role != "user" || request.Messages[0].Content != "北京和上海的天气" {
+		t.Errorf("messages = %+v", request.Messages)
+	}
+	var ids []string
+	for _, call := range message.GetToolCalls() {
+		ids = append(ids, call.GetID())
+	}
+	if strings.Join(ids, ",") != "call_a,call_b" {
+		t.Errorf("tool calls = %v", ids)
+	}
+	if input, output := message.GetUsage(); input != 5 || output != 3 {
+		t.Errorf("usage = %d, %d", input, output)
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{
+			name:   "rate limited",
+			status: http.StatusTooManyRequests,
+			body:   `{"error":{"message":"Rate limit reached","type":"requests"}}`,
+			want:   "openai: overloaded_error (status 429): requests: Rate limit reached",
+		},
+		{
+			name:   "unavailable",
+			status: http.StatusServiceUnavailable,
+			body:   `upstream unavailable`,
+			want:   "openai: overloaded_error (status 503): upstream unavailable",
+		},
+		{
+			name:   "bad request",
+			status: http.StatusBadRequest,
+			body:   `{"error":{"message":"Invalid schema for function","type":"invalid_request_error"}}`,
+			want:   "openai: 请求失败 (status 400): invalid_request_error: Invalid schema for function",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t, nil, func(w http.ResponseWriter) {
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			})
+			_, err := p.CreateMessagestream(context.Background(), "hi", nil, nil, make(chan string, 1))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateMessagestreamCanceled(t *testing.T) {
+	written := make(chan struct{})
+	p := newTestProvider(t, nil, func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"content":"你好"}}]}`+"\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+		close(written)
+	})
+
+	// 没有人读取 contentChan 时，取消 ctx 后应该返回而不是一直阻塞
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.CreateMessagestream(ctx, "你好", nil, nil, make(chan string))
+		done <- err
+	}()
+	// 等响应读完、提供者阻塞在写入 contentChan 上之后再取消
+	<-written
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("error = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("CreateMessagestream() blocked after ctx was canceled")
+	}
+}
@@ -0,0 +1,180 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"mcpclient/llm"
+)
+
+// ==========================
+// Chat Completions 协议结构
+// ==========================
+
+// chatRequest 对应 POST /chat/completions 的请求体
+type chatRequest struct {
+	Model         string         `json:"model"`
+	Messages      []chatMessage  `json:"messages"`
+	Tools         []chatTool     `json:"tools,omitempty"`
+	Temperature   *float64       `json:"temperature,omitempty"`
+	TopP          *float64       `json:"top_p,omitempty"`
+	MaxTokens     *int           `json:"max_tokens,omitempty"`
+	Stop          []string       `json:"stop,omitempty"`
+	Seed          *int           `json:"seed,omitempty"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+}
+
+// streamOptions 流式请求的附加选项，用于在最后一个分片中返回 token 使用情况
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// chatMessage 表示一条 Chat Completions 消息
+type chatMessage struct {
+	Role       string         `json:"role"`
+	Content    string         `json:"content"`
+	ToolCalls  []chatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+// chatTool 表示一个可供模型调用的函数
+type chatTool struct {
+	Type     string       `json:"type"`
+	Function chatFunction `json:"function"`
+}
+
+// chatFunction 函数的名称、描述以及 JSON Schema 形式的参数定义
+type chatFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// chatToolCall 表示模型发起的一次函数调用
+// 流式响应中 Index 用于把同一个调用的多个分片拼接起来
+type chatToolCall struct {
+	Index    *int             `json:"index,omitempty"`
+	ID       string           `json:"id,omitempty"`
+	Type     string           `json:"type,omitempty"`
+	Function chatCallFunction `json:"function"`
+}
+
+// chatCallFunction 函数调用的名称与参数（参数为 JSON 字符串）
+type chatCallFunction struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+// chatResponse 非流式响应以及流式响应中每个分片的结构
+type chatResponse struct {
+	ID      string       `json:"id"`
+	Model   string       `json:"model"`
+	Choices []chatChoice `json:"choices"`
+	Usage   *chatUsage   `json:"usage,omitempty"`
+}
+
+// chatChoice 非流式响应使用 Message，流式响应使用 Delta
+type chatChoice struct {
+	Index        int         `json:"index"`
+	Message      chatMessage `json:"message"`
+	Delta        chatMessage `json:"delta"`
+	FinishReason string      `json:"finish_reason"`
+}
+
+// chatUsage token 使用情况
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// errorResponse 接口返回的错误信息
+type errorResponse struct {
+	Error struct {
+		Message string      `json:"message"`
+		Type    string      `json:"type"`
+		Code    interface{} `json:"code"`
+	} `json:"error"`
+}
+
+// ==========================
+// 适配 llm.Message 接口
+// ==========================
+
+// OpenAIMessage 将 Chat Completions 的消息格式适配为我们自己的 Message 接口
+type OpenAIMessage struct {
+	Message chatMessage // 储存 OpenAI 消息
+	Usage   chatUsage   // 本次请求的 token 使用情况
+}
+
+// 获取消息的角色
+func (m *OpenAIMessage) GetRole() string {
+	return m.Message.Role
+}
+
+// 获取消息内容（去掉两端的空白字符）
+func (m *OpenAIMessage) GetContent() string {
+	return strings.TrimSpace(m.Message.Content)
+}
+
+// 获取工具调用（将 OpenAI 格式的工具调用转换为我们定义的工具调用）
+func (m *OpenAIMessage) GetToolCalls() []llm.ToolCall {
+	var calls []llm.ToolCall
+	for _, call := range m.Message.ToolCalls {
+		calls = append(calls, NewOpenAIToolCall(call))
+	}
+	return calls
+}
+
+// 获取消息的 token 使用情况
+func (m *OpenAIMessage) GetUsage() (int, int) {
+	return m.Usage.PromptTokens, m.Usage.CompletionTokens
+}
+
+// 判断消息是否为工具响应
+func (m *OpenAIMessage) IsToolResponse() bool {
+	return m.Message.Role == "tool"
+}
+
+// 获取工具响应的 ID
+func (m *OpenAIMessage) GetToolResponseID() string {
+	return m.Message.ToolCallID
+}
+
+// OpenAIToolCall 将 OpenAI 的函数调用格式适配为我们自己的工具调用格式
+type OpenAIToolCall struct {
+	call chatToolCall // 储存 OpenAI 的函数调用
+}
+
+// 创建一个新的 OpenAI 工具调用，如果服务端没有返回 ID 则生成一个
+func NewOpenAIToolCall(call chatToolCall) *OpenAIToolCall {
+	if call.ID == "" {
+		call.ID = fmt.Sprintf("tc_%s_%d", call.Function.Name, time.Now().UnixNano())
+	}
+	return &OpenAIToolCall{call: call}
+}
+
+// 获取工具调用的名称
+func (t *OpenAIToolCall) GetName() string {
+	return t.call.Function.Name
+}
+
+// 获取工具调用的参数（将 JSON 字符串解析为 map）
+func (t *OpenAIToolCall) GetArguments() map[string]interface{} {
+	args := make(map[string]interface{})
+	if strings.TrimSpace(t.call.Function.Arguments) == "" {
+		return args
+	}
+	if err := json.Unmarshal([]byte(t.call.Function.Arguments), &args); err != nil {
+		return make(map[string]interface{}) // 解析失败返回空 map
+	}
+	return args
+}
+
+// 获取工具调用的 ID
+func (t *OpenAIToolCall) GetID() string {
+	return t.call.ID
+}
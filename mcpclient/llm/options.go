@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"fmt"
+)
+
+// ==========================
+// 定义 Options 结构体
+// ==========================
+
+// Options 表示单次请求的生成参数
+// 所有字段均为可选，未设置时使用模型服务自身的默认值
+type Options struct {
+	// Temperature 采样温度，取值范围 0~2
+	Temperature *float64 `json:"temperature,omitempty" mapstructure:"temperature"`
+
+	// TopP 核采样概率，取值范围 0~1
+	TopP *float64 `json:"top_p,omitempty" mapstructure:"top_p"`
+
+	// MaxTokens 单次响应生成的最大 token 数（Ollama 中对应 num_predict）
+	MaxTokens *int `json:"max_tokens,omitempty" mapstructure:"max_tokens"`
+
+	// Stop 停止序列，生成到其中任意一个时停止
+	Stop []string `json:"stop,omitempty" mapstructure:"stop"`
+
+	// Seed 随机种子，相同种子和输入可以得到可复现的输出
+	Seed *int `json:"seed,omitempty" mapstructure:"seed"`
+
+	// NumCtx 上下文窗口大小（仅 Ollama 支持）
+	NumCtx *int `json:"num_ctx,omitempty" mapstructure:"num_ctx"`
+}
+
+// Merge 返回合并后的参数，override 中设置了的字段覆盖当前值
+func (o Options) Merge(override Options) Options {
+	if override.Temperature != nil {
+		o.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		o.TopP = override.TopP
+	}
+	if override.MaxTokens != nil {
+		o.MaxTokens = override.MaxTokens
+	}
+	if override.Stop != nil {
+		o.Stop = override.Stop
+	}
+	if override.Seed != nil {
+		o.Seed = override.Seed
+	}
+	if override.NumCtx != nil {
+		o.NumCtx = override.NumCtx
+	}
+	return o
+}
+
+// Validate 检查参数是否在合法范围内
+func (o Options) Validate() error {
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > 2) {
+		return fmt.Errorf("temperature 取值范围为 0~2，实际为 %v", *o.Temperature)
+	}
+	if o.TopP != nil && (*o.TopP < 0 || *o.TopP > 1) {
+		return fmt.Errorf("top_p 取值范围为 0~1，实际为 %v", *o.TopP)
+	}
+	if o.MaxTokens != nil && *o.MaxTokens <= 0 {
+		return fmt.Errorf("max_tokens 必须大于 0，实际为 %d", *o.MaxTokens)
+	}
+	if o.NumCtx != nil && *o.NumCtx <= 0 {
+		return fmt.Errorf("num_ctx 必须大于 0，实际为 %d", *o.NumCtx)
+	}
+	if len(o.Stop) > 4 {
+		return fmt.Errorf("stop 最多支持 4 个停止序列，实际为 %d", len(o.Stop))
+	}
+	return nil
+}
@@ -105,6 +105,41 @@ type Schema struct {
 	// Required 定义必要的参数
 	// 例如：["city", "unit"] 表示 city 和 unit 是必需的参数
 	Required []string `json:"required"`
+
+	// AdditionalProperties 是否允许未定义的参数，可以是 bool 或者描述额外参数的 Schema
+	// 为空表示不限制
+	AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
+
+	// Defs 可以被 Properties 中的 "$ref": "#/$defs/名称" 引用的子 Schema
+	Defs map[string]interface{} `json:"$defs,omitempty"`
+}
+
+// Map 将 Schema 转换为 JSON Schema 对象，作为各提供者请求中的工具参数定义
+// 没有类型时按 object 处理；属性为空时使用空对象，部分模型的模板无法处理 null；
+// 属性的定义原样保留，其中的 $ref 引用 $defs 中的定义
+func (s Schema) Map() map[string]interface{} {
+	schemaType := s.Type
+	if schemaType == "" {
+		schemaType = "object"
+	}
+	properties := s.Properties
+	if properties == nil {
+		properties = map[string]interface{}{}
+	}
+	result := map[string]interface{}{
+		"type":       schemaType,
+		"properties": properties,
+	}
+	if len(s.Required) > 0 {
+		result["required"] = s.Required
+	}
+	if s.AdditionalProperties != nil {
+		result["additionalProperties"] = s.AdditionalProperties
+	}
+	if len(s.Defs) > 0 {
+		result["$defs"] = s.Defs
+	}
+	return result
 }
 
 // ==========================
@@ -124,7 +159,11 @@ type Provider interface {
 	// - Message：LLM 生成的响应消息
 	// - error：如果发生错误，返回具体的错误信息
 	CreateMessage(ctx context.Context, prompt string, messages []Message, tools []Tool) (Message, error)
+
+	// CreateMessagestream 以流式方式发送消息，生成的文本分片会依次写入 contentChan
+	// contentChan 由调用方创建和关闭，提供者不会关闭它
 	CreateMessagestream(ctx context.Context, prompt string, messages []Message, tools []Tool, contentChan chan<- string) (Message, error)
+
 	// CreateToolResponse 创建一个工具调用的响应消息
 	// - toolCallID：工具调用的唯一标识符
 	// - content：工具调用的结果数据（格式可能是字符串或 JSON）
@@ -142,4 +181,9 @@ type Provider interface {
 	// Name 返回当前提供者的名称
 	// 例如："OpenAI", "Anthropic", "Mistral"
 	Name() string
+
+	// WithOptions 返回一个使用指定生成参数的提供者副本
+	// 原提供者不受影响，因此可以在并发的请求之间安全地共享
+	// - opts：温度、top_p、最大 token 数等生成参数
+	WithOptions(opts Options) Provider
 }
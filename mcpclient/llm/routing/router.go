@@ -0,0 +1,234 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"mcpclient/config"
+	"mcpclient/llm"
+)
+
+// Factory 根据 provider:model 格式的字符串创建提供者
+type Factory func(spec string) (llm.Provider, error)
+
+// Request 描述一次对话请求中用于路由的信息
+type Request struct {
+	ToolsRequired bool   // 本轮是否需要工具调用
+	PromptLength  int    // 上下文长度（历史消息和提示词的字符数）
+	UserTier      string // 用户等级，例如 free、pro
+}
+
+// Decision 路由决策结果
+type Decision struct {
+	Rule     string      // 命中的规则名称，未命中时为 default，指定模型时为 model
+	Provider string      // 选择的提供者配置
+	Reason   string      // 选择原因
+	Options  llm.Options // 该模型在配置中声明的默认生成参数
+}
+
+// ErrModelNotAllowed 请求的模型不在白名单中
+var ErrModelNotAllowed = errors.New("模型不在允许的列表中")
+
+// route 一条已经创建好提供者的路由规则
+type route struct {
+	rule     config.RoutingRule
+	provider llm.Provider
+}
+
+// model 白名单中一个已经创建好提供者的模型
+type model struct {
+	config   config.ModelConfig
+	provider llm.Provider
+}
+
+// Router 根据规则为每次请求选择提供者
+// 规则按配置顺序匹配，第一条满足所有条件的规则生效，都不满足时使用默认提供者
+type Router struct {
+	routes      []route
+	models      map[string]model       // 白名单中的模型，按名称索引
+	defaults    map[string]llm.Options // 各提供者配置对应的默认生成参数
+	def         llm.Provider           // 默认提供者
+	defSpec     string                 // 默认提供者配置
+	defaultTier string                 // 请求中没有用户等级时使用的等级
+}
+
+// NewRouter 根据配置创建路由器，每条规则和白名单模型的提供者在创建时通过 factory 初始化
+func NewRouter(
+	cfg config.RoutingConfig,
+	models []config.ModelConfig,
+	def llm.Provider,
+	defSpec string,
+	factory Factory,
+) (*Router, error) {
+	r := &Router{
+		models:      make(map[string]model),
+		defaults:    make(map[string]llm.Options),
+		def:         def,
+		defSpec:     defSpec,
+		defaultTier: cfg.DefaultTier,
+	}
+	if r.defaultTier == "" {
+		r.defaultTier = "free"
+	}
+
+	// 缓存相同配置的提供者，避免重复创建
+	providers := map[string]llm.Provider{defSpec: def}
+	getProvider := func(spec string) (llm.Provider, error) {
+		if provider, ok := providers[spec]; ok {
+			return provider, nil
+		}
+		provider, err := factory(spec)
+		if err != nil {
+			return nil, err
+		}
+		providers[spec] = provider
+		return provider, nil
+	}
+
+	// 路由规则和默认提供者按提供者配置查找默认参数，
+	// 多个模型使用相同的提供者配置时默认参数必须一致，否则无法确定使用哪一个
+	owners := make(map[string]string, len(models))
+	for _, m := range models {
+		if err := m.Options.Validate(); err != nil {
+			return nil, fmt.Errorf("模型 %s 的默认参数无效: %w", m.Name, err)
+		}
+		if owner, ok := owners[m.Provider]; ok && !reflect.DeepEqual(r.defaults[m.Provider], m.Options) {
+			return nil, fmt.Errorf("模型 %s 和 %s 使用相同的提供者 %s，但默认参数不同", owner, m.Name, m.Provider)
+		}
+		provider, err := getProvider(m.Provider)
+		if err != nil {
+			return nil, fmt.Errorf("创建模型 %s 的提供者失败: %w", m.Name, err)
+		}
+		r.models[m.Name] = model{config: m, provider: provider}
+		r.defaults[m.Provider] = m.Options
+		owners[m.Provider] = m.Name
+	}
+
+	for i, rule := range cfg.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		provider, err := getProvider(rule.Provider)
+		if err != nil {
+			return nil, fmt.Errorf("创建路由规则 %s 的提供者失败: %w", rule.Name, err)
+		}
+		r.routes = append(r.routes, route{rule: rule, provider: provider})
+	}
+	return r, nil
+}
+
+// Models 返回白名单中的模型名称
+func (r *Router) Models() []string {
+	names := make([]string, 0, len(r.models))
+	for name := range r.models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Select 为请求选择提供者
+// 请求指定了模型时从白名单中查找，不在白名单中返回 ErrModelNotAllowed；否则按路由规则选择
+func (r *Router) Select(ctx context.Context, modelName string, req Request) (llm.Provider, Decision, error) {
+	if modelName == "" {
+		provider, decision := r.Route(ctx, req)
+		return provider, decision, nil
+	}
+
+	m, ok := r.models[modelName]
+	if !ok {
+		return nil, Decision{}, fmt.Errorf("%w: %s", ErrModelNotAllowed, modelName)
+	}
+	decision := Decision{
+		Rule:     "model",
+		Provider: m.config.Provider,
+		Reason:   "请求指定模型 " + modelName,
+		Options:  m.config.Options,
+	}
+	log.Info("路由决策",
+		"rule", decision.Rule,
+		"provider", decision.Provider,
+		"reason", decision.Reason)
+	llm.TraceFromContext(ctx).SetDecision(decision.Rule, decision.Provider, decision.Reason)
+	return m.provider, decision, nil
+}
+
+// Route 为请求选择提供者，并将决策记录到 ctx 中的 Trace
+func (r *Router) Route(ctx context.Context, req Request) (llm.Provider, Decision) {
+	if req.UserTier == "" {
+		req.UserTier = r.defaultTier
+	}
+
+	provider, decision := r.def, Decision{
+		Rule:     "default",
+		Provider: r.defSpec,
+		Reason:   "没有匹配的路由规则",
+		Options:  r.defaults[r.defSpec],
+	}
+	for _, route := range r.routes {
+		if reason, ok := match(route.rule, req); ok {
+			provider, decision = route.provider, Decision{
+				Rule:     route.rule.Name,
+				Provider: route.rule.Provider,
+				Reason:   reason,
+				Options:  r.defaults[route.rule.Provider],
+			}
+			break
+		}
+	}
+
+	log.Info("路由决策",
+		"rule", decision.Rule,
+		"provider", decision.Provider,
+		"reason", decision.Reason,
+		"tools_required", req.ToolsRequired,
+		"prompt_length", req.PromptLength,
+		"tier", req.UserTier)
+	llm.TraceFromContext(ctx).SetDecision(decision.Rule, decision.Provider, decision.Reason)
+	return provider, decision
+}
+
+// match 判断请求是否满足规则的所有条件，满足时返回原因描述
+func match(rule config.RoutingRule, req Request) (string, bool) {
+	reason := ""
+	if rule.RequiresTools != nil {
+		if *rule.RequiresTools != req.ToolsRequired {
+			return "", false
+		}
+		reason += fmt.Sprintf("tools_required=%t ", req.ToolsRequired)
+	}
+	if rule.MinPromptLength > 0 {
+		if req.PromptLength < rule.MinPromptLength {
+			return "", false
+		}
+		reason += fmt.Sprintf("prompt_length>=%d ", rule.MinPromptLength)
+	}
+	if rule.MaxPromptLength > 0 {
+		if req.PromptLength > rule.MaxPromptLength {
+			return "", false
+		}
+		reason += fmt.Sprintf("prompt_length<=%d ", rule.MaxPromptLength)
+	}
+	if len(rule.Tiers) > 0 {
+		matched := false
+		for _, tier := range rule.Tiers {
+			if tier == req.UserTier {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return "", false
+		}
+		reason += fmt.Sprintf("tier=%s ", req.UserTier)
+	}
+	if reason == "" {
+		reason = "无条件规则"
+	}
+	return strings.TrimSpace(reason), true
+}
@@ -0,0 +1,91 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"mcpclient/config"
+	"mcpclient/llm"
+	"mcpclient/llm/mock"
+)
+
+// named 带名称的 mock 提供者，名称为提供者配置，用于判断路由选择的提供者
+type named struct {
+	*mock.Provider
+	name string
+}
+
+func (p named) Name() string { return p.name }
+
+func factory(spec string) (llm.Provider, error) {
+	return named{mock.NewProvider(nil), spec}, nil
+}
+
+func newTestRouter(t *testing.T) *Router {
+	t.Helper()
+	yes, no, maxTokens := true, false, 256
+	cfg := config.RoutingConfig{Rules: []config.RoutingRule{
+		{Name: "long", Provider: "anthropic:long", MinPromptLength: 1000},
+		{Name: "pro-tools", Provider: "openai:pro", RequiresTools: &yes, Tiers: []string{"pro"}},
+		{Name: "tools", Provider: "openai:tools", RequiresTools: &yes},
+		{Name: "short-chat", Provider: "ollama:small", RequiresTools: &no, MaxPromptLength: 100},
+	}}
+	models := []config.ModelConfig{
+		{Name: "small", Provider: "ollama:small", Options: llm.Options{MaxTokens: &maxTokens}},
+	}
+	def, _ := factory("ollama:default")
+	r, err := NewRouter(cfg, models, def, "ollama:default", factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRoute(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      Request
+		rule     string
+		provider string
+	}{
+		{name: "prompt length first", req: Request{ToolsRequired: true, PromptLength: 5000, UserTier: "pro"}, rule: "long", provider: "anthropic:long"},
+		{name: "tools and tier", req: Request{ToolsRequired: true, PromptLength: 10, UserTier: "pro"}, rule: "pro-tools", provider: "openai:pro"},
+		{name: "tools for other tiers", req: Request{ToolsRequired: true, PromptLength: 10, UserTier: "free"}, rule: "tools", provider: "openai:tools"},
+		{name: "default tier", req: Request{ToolsRequired: true, PromptLength: 10}, rule: "tools", provider: "openai:tools"},
+		{name: "short chat without tools", req: Request{PromptLength: 100}, rule: "short-chat", provider: "ollama:small"},
+		{name: "no rule matches", req: Request{PromptLength: 101}, rule: "default", provider: "ollama:default"},
+	}
+	r := newTestRouter(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trace := &llm.Trace{}
+			provider, decision := r.Route(llm.WithTrace(context.Background(), trace), tt.req)
+			if decision.Rule != tt.rule || provider.Name() != tt.provider || decision.Provider != tt.provider {
+				t.Errorf("Route() = %s (%+v), want rule %s provider %s", provider.Name(), decision, tt.rule, tt.provider)
+			}
+			if trace.Rule != tt.rule {
+				t.Errorf("trace rule = %q, want %q", trace.Rule, tt.rule)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	r := newTestRouter(t)
+
+	provider, decision, err := r.Select(context.Background(), "small", Request{ToolsRequired: true})
+	if err != nil || provider.Name() != "ollama:small" || decision.Rule != "model" || decision.Options.MaxTokens == nil || *decision.Options.MaxTokens != 256 {
+		t.Errorf("Select(small) = %v, %+v, %v", provider, decision, err)
+	}
+
+	// 路由规则选中的提供者也使用白名单中声明的默认参数
+	_, decision = r.Route(context.Background(), Request{PromptLength: 10})
+	if decision.Options.MaxTokens == nil || *decision.Options.MaxTokens != 256 {
+		t.Errorf("Route() options = %+v, want model defaults", decision.Options)
+	}
+
+	if _, _, err := r.Select(context.Background(), "gpt-4o", Request{}); !errors.Is(err, ErrModelNotAllowed) {
+		t.Errorf("Select(gpt-4o) error = %v, want ErrModelNotAllowed", err)
+	}
+}
@@ -0,0 +1,411 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ==========================
+// 工具参数校验
+// ==========================
+
+// $ref 展开的最大深度，防止递归定义的 Schema 无限展开
+const maxSchemaDepth = 32
+
+// SchemaError 参数中一处不符合 Schema 的地方
+type SchemaError struct {
+	// Path 出错位置的 JSON Pointer，例如 "/items/0/name"，根对象为 "/"
+	Path string `json:"path"`
+	// Message 错误说明
+	Message string `json:"message"`
+}
+
+func (e SchemaError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// Validate 按 JSON Schema 校验工具参数，返回所有不符合的地方，参数合法时返回空
+// 支持 type、enum、const、properties、required、additionalProperties、items、
+// 长度与取值范围、pattern、allOf/anyOf/oneOf/not，以及指向 $defs 的 $ref；
+// 其他关键字（例如 format）不做校验，交给 MCP 服务器处理
+func (s Schema) Validate(arguments map[string]interface{}) []SchemaError {
+	root := s.Map()
+
+	v := &schemaValidator{defs: s.Defs}
+	var instance interface{} = arguments
+	if arguments == nil {
+		// 模型没有给出参数时按空对象处理
+		instance = map[string]interface{}{}
+	}
+	v.validate(root, instance, "", 0)
+	return v.errors
+}
+
+// schemaValidator 保存一次校验中收集到的错误
+type schemaValidator struct {
+	defs   map[string]interface{}
+	errors []SchemaError
+}
+
+func (v *schemaValidator) fail(path, format string, args ...interface{}) {
+	if path == "" {
+		path = "/"
+	}
+	v.errors = append(v.errors, SchemaError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// validate 校验 instance 是否符合 schema，schema 为 bool 或 map，其他类型不做限制
+func (v *schemaValidator) validate(schema interface{}, instance interface{}, path string, depth int) {
+	if depth > maxSchemaDepth {
+		v.fail(path, "Schema 嵌套过深，无法校验")
+		return
+	}
+	switch s := schema.(type) {
+	case bool:
+		if !s {
+			v.fail(path, "不允许出现这个值")
+		}
+		return
+	case map[string]interface{}:
+		v.validateObjectSchema(s, instance, path, depth)
+	}
+}
+
+func (v *schemaValidator) validateObjectSchema(s map[string]interface{}, instance interface{}, path string, depth int) {
+	if ref, ok := s["$ref"].(string); ok {
+		target, err := v.resolveRef(ref)
+		if err != nil {
+			v.fail(path, "%v", err)
+		} else {
+			v.validate(target, instance, path, depth+1)
+		}
+	}
+
+	// 类型不符时不再检查这个值的其他关键字，避免产生大量重复的错误
+	if t, ok := s["type"]; ok && !v.checkType(t, instance, path) {
+		return
+	}
+
+	if enum, ok := s["enum"].([]interface{}); ok {
+		matched := false
+		for _, candidate := range enum {
+			if jsonEqual(candidate, instance) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(path, "值 %s 不在允许的取值 %s 中", jsonString(instance), jsonString(enum))
+		}
+	}
+	if constant, ok := s["const"]; ok && !jsonEqual(constant, instance) {
+		v.fail(path, "值必须为 %s", jsonString(constant))
+	}
+
+	switch value := instance.(type) {
+	case map[string]interface{}:
+		v.validateObject(s, value, path, depth)
+	case []interface{}:
+		v.validateArray(s, value, path, depth)
+	case string:
+		v.validateString(s, value, path)
+	default:
+		if number, ok := toNumber(instance); ok {
+			v.validateNumber(s, number, path)
+		}
+	}
+
+	v.validateCombinators(s, instance, path, depth)
+}
+
+// checkType 检查值的类型，type 可以是字符串或字符串数组
+func (v *schemaValidator) checkType(t interface{}, instance interface{}, path string) bool {
+	var types []string
+	switch t := t.(type) {
+	case string:
+		types = []string{t}
+	case []interface{}:
+		for _, item := range t {
+			if name, ok := item.(string); ok {
+				types = append(types, name)
+			}
+		}
+	case []string:
+		types = t
+	}
+	if len(types) == 0 {
+		return true
+	}
+	actual := jsonType(instance)
+	for _, name := range types {
+		if name == actual || (name == "number" && actual == "integer") {
+			return true
+		}
+	}
+	v.fail(path, "类型应为 %s，实际为 %s", strings.Join(types, " 或 "), actual)
+	return false
+}
+
+func (v *schemaValidator) validateObject(s map[string]interface{}, object map[string]interface{}, path string, depth int) {
+	properties, _ := s["properties"].(map[string]interface{})
+
+	for _, name := range toStrings(s["required"]) {
+		if _, ok := object[name]; !ok {
+			v.fail(path, "缺少必填参数 %s", name)
+		}
+	}
+
+	// 按名称排序，保证错误的顺序稳定
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		childPath := path + "/" + escapePointer(name)
+		if property, ok := properties[name]; ok {
+			v.validate(property, object[name], childPath, depth+1)
+			continue
+		}
+		switch additional := s["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.fail(childPath, "不允许的参数 %s，可用的参数为 %s", name, strings.Join(sortedKeys(properties), ", "))
+			}
+		case map[string]interface{}:
+			v.validate(additional, object[name], childPath, depth+1)
+		}
+	}
+
+	if min, ok := toInt(s["minProperties"]); ok && len(object) < min {
+		v.fail(path, "至少需要 %d 个参数，实际为 %d 个", min, len(object))
+	}
+	if max, ok := toInt(s["maxProperties"]); ok && len(object) > max {
+		v.fail(path, "最多允许 %d 个参数，实际为 %d 个", max, len(object))
+	}
+}
+
+func (v *schemaValidator) validateArray(s map[string]interface{}, array []interface{}, path string, depth int) {
+	if items, ok := s["items"]; ok {
+		for i, item := range array {
+			v.validate(items, item, path+"/"+strconv.Itoa(i), depth+1)
+		}
+	}
+	if min, ok := toInt(s["minItems"]); ok && len(array) < min {
+		v.fail(path, "至少需要 %d 个元素，实际为 %d 个", min, len(array))
+	}
+	if max, ok := toInt(s["maxItems"]); ok && len(array) > max {
+		v.fail(path, "最多允许 %d 个元素，实际为 %d 个", max, len(array))
+	}
+	if unique, _ := s["uniqueItems"].(bool); unique {
+		seen := make(map[string]int, len(array))
+		for i, item := range array {
+			key := jsonString(item)
+			if j, ok := seen[key]; ok {
+				v.fail(path, "元素不能重复，第 %d 个元素与第 %d 个元素相同", i, j)
+				break
+			}
+			seen[key] = i
+		}
+	}
+}
+
+func (v *schemaValidator) validateString(s map[string]interface{}, value string, path string) {
+	length := utf8.RuneCountInString(value)
+	if min, ok := toInt(s["minLength"]); ok && length < min {
+		v.fail(path, "长度至少为 %d，实际为 %d", min, length)
+	}
+	if max, ok := toInt(s["maxLength"]); ok && length > max {
+		v.fail(path, "长度最多为 %d，实际为 %d", max, length)
+	}
+	if pattern, ok := s["pattern"].(string); ok {
+		// 无法编译的正则表达式是服务器的问题，不影响调用
+		if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(value) {
+			v.fail(path, "值 %q 不匹配 %s", value, pattern)
+		}
+	}
+}
+
+func (v *schemaValidator) validateNumber(s map[string]interface{}, value float64, path string) {
+	if min, ok := toNumber(s["minimum"]); ok && value < min {
+		v.fail(path, "值 %v 小于最小值 %v", value, min)
+	}
+	if max, ok := toNumber(s["maximum"]); ok && value > max {
+		v.fail(path, "值 %v 大于最大值 %v", value, max)
+	}
+	if min, ok := toNumber(s["exclusiveMinimum"]); ok && value <= min {
+		v.fail(path, "值 %v 必须大于 %v", value, min)
+	}
+	if max, ok := toNumber(s["exclusiveMaximum"]); ok && value >= max {
+		v.fail(path, "值 %v 必须小于 %v", value, max)
+	}
+	if multiple, ok := toNumber(s["multipleOf"]); ok && multiple > 0 {
+		if q := value / multiple; math.Abs(q-math.Round(q)) > 1e-9 {
+			v.fail(path, "值 %v 必须是 %v 的倍数", value, multiple)
+		}
+	}
+}
+
+// validateCombinators 校验 allOf、anyOf、oneOf 和 not
+// anyOf、oneOf 的分支各自在独立的校验器中执行，只把整体结果报告出来
+func (v *schemaValidator) validateCombinators(s map[string]interface{}, instance interface{}, path string, depth int) {
+	if allOf, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			v.validate(sub, instance, path, depth+1)
+		}
+	}
+
+	matches := func(branches []interface{}) (int, []SchemaError) {
+		count := 0
+		var firstErrors []SchemaError
+		for _, sub := range branches {
+			branch := &schemaValidator{defs: v.defs}
+			branch.validate(sub, instance, path, depth+1)
+			if len(branch.errors) == 0 {
+				count++
+			} else if firstErrors == nil {
+				firstErrors = branch.errors
+			}
+		}
+		return count, firstErrors
+	}
+	if anyOf, ok := s["anyOf"].([]interface{}); ok {
+		if count, errs := matches(anyOf); count == 0 {
+			v.fail(path, "值不符合 anyOf 中的任何一种定义%s", branchHint(errs))
+		}
+	}
+	if oneOf, ok := s["oneOf"].([]interface{}); ok {
+		switch count, errs := matches(oneOf); {
+		case count == 0:
+			v.fail(path, "值不符合 oneOf 中的任何一种定义%s", branchHint(errs))
+		case count > 1:
+			v.fail(path, "值同时符合 oneOf 中的 %d 种定义，只能符合一种", count)
+		}
+	}
+	if not, ok := s["not"]; ok {
+		branch := &schemaValidator{defs: v.defs}
+		branch.validate(not, instance, path, depth+1)
+		if len(branch.errors) == 0 {
+			v.fail(path, "值不能符合 not 中的定义")
+		}
+	}
+}
+
+// resolveRef 解析指向 $defs（或 definitions）的本地引用
+func (v *schemaValidator) resolveRef(ref string) (interface{}, error) {
+	for _, prefix := range []string{"#/$defs/", "#/definitions/"} {
+		if name, ok := strings.CutPrefix(ref, prefix); ok {
+			name = strings.ReplaceAll(strings.ReplaceAll(name, "~1", "/"), "~0", "~")
+			if target, ok := v.defs[name]; ok {
+				return target, nil
+			}
+			return nil, fmt.Errorf("找不到引用的定义 %s", ref)
+		}
+	}
+	return nil, fmt.Errorf("不支持的引用 %s", ref)
+}
+
+// branchHint 给出第一个不匹配的分支的错误，帮助模型修正
+func branchHint(errs []SchemaError) string {
+	if len(errs) == 0 {
+		return ""
+	}
+	return "（第一种定义：" + errs[0].Error() + "）"
+}
+
+// jsonType 返回值的 JSON 类型，没有小数部分的数字为 integer
+func jsonType(instance interface{}) string {
+	switch instance.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	if number, ok := toNumber(instance); ok {
+		if number == math.Trunc(number) && !math.IsInf(number, 0) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", instance)
+}
+
+// toNumber 把各提供者解析出来的数字统一为 float64
+func toNumber(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toInt(value interface{}) (int, bool) {
+	n, ok := toNumber(value)
+	return int(n), ok
+}
+
+func toStrings(value interface{}) []string {
+	switch items := value.(type) {
+	case []string:
+		return items
+	case []interface{}:
+		names := make([]string, 0, len(items))
+		for _, item := range items {
+			if name, ok := item.(string); ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// jsonEqual 按 JSON 语义比较两个值，忽略数字的具体类型
+func jsonEqual(a, b interface{}) bool {
+	return jsonString(a) == jsonString(b)
+}
+
+func jsonString(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// escapePointer 按 JSON Pointer 的规则转义属性名
+func escapePointer(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,185 @@
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// mustSchema 按 MCP 服务器返回的 JSON 解析工具的输入 Schema
+func mustSchema(t *testing.T, data string) Schema {
+	t.Helper()
+	var schema Schema
+	if err := json.Unmarshal([]byte(data), &schema); err != nil {
+		t.Fatalf("unmarshal schema: %v", err)
+	}
+	return schema
+}
+
+func mustArguments(t *testing.T, data string) map[string]interface{} {
+	t.Helper()
+	var arguments map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &arguments); err != nil {
+		t.Fatalf("unmarshal arguments: %v", err)
+	}
+	return arguments
+}
+
+func TestSchemaValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		schema    string
+		arguments string
+		errors    []string // 期望的错误，格式为 "路径: 说明中包含的内容"
+	}{
+		{
+			name:      "required present",
+			schema:    `{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]}`,
+			arguments: `{"name":"mcp"}`,
+		},
+		{
+			name:      "required missing",
+			schema:    `{"type":"object","properties":{"name":{"type":"string"},"age":{"type":"integer"}},"required":["name","age"]}`,
+			arguments: `{}`,
+			errors:    []string{"/: 缺少必填参数 name", "/: 缺少必填参数 age"},
+		},
+		{
+			name:      "nil arguments are an empty object",
+			schema:    `{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]}`,
+			arguments: `null`,
+			errors:    []string{"/: 缺少必填参数 name"},
+		},
+		{
+			name:      "additionalProperties false",
+			schema:    `{"type":"object","properties":{"name":{"type":"string"}},"additionalProperties":false}`,
+			arguments: `{"name":"mcp","nmae":"typo"}`,
+			errors:    []string{"/nmae: 不允许的参数 nmae"},
+		},
+		{
+			name:      "additionalProperties schema",
+			schema:    `{"type":"object","additionalProperties":{"type":"number"}}`,
+			arguments: `{"a":1,"b":"two"}`,
+			errors:    []string{"/b: 类型应为 number，实际为 string"},
+		},
+		{
+			name:      "additionalProperties allowed by default",
+			schema:    `{"type":"object","properties":{"name":{"type":"string"}}}`,
+			arguments: `{"name":"mcp","extra":true}`,
+		},
+		{
+			name: "$ref to $defs",
+			schema: `{"type":"object","properties":{"owner":{"$ref":"#/$defs/person"}},
+				"$defs":{"person":{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]}}}`,
+			arguments: `{"owner":{"name":1}}`,
+			errors:    []string{"/owner/name: 类型应为 string，实际为 integer"},
+		},
+		{
+			name: "recursive $ref",
+			schema: `{"type":"object","properties":{"tree":{"$ref":"#/$defs/node"}},
+				"$defs":{"node":{"type":"object","properties":{"children":{"type":"array","items":{"$ref":"#/$defs/node"}}},"additionalProperties":false}}}`,
+			arguments: `{"tree":{"children":[{"children":[{"value":1}]}]}}`,
+			errors:    []string{"/tree/children/0/children/0/value: 不允许的参数 value"},
+		},
+		{
+			name:      "missing $ref target",
+			schema:    `{"type":"object","properties":{"owner":{"$ref":"#/$defs/missing"}}}`,
+			arguments: `{"owner":{}}`,
+			errors:    []string{"/owner: 找不到引用的定义 #/$defs/missing"},
+		},
+		{
+			name:      "oneOf matches one",
+			schema:    `{"type":"object","properties":{"id":{"oneOf":[{"type":"string"},{"type":"integer"}]}}}`,
+			arguments: `{"id":"abc"}`,
+		},
+		{
+			name:      "oneOf matches none",
+			schema:    `{"type":"object","properties":{"id":{"oneOf":[{"type":"string"},{"type":"integer"}]}}}`,
+			arguments: `{"id":true}`,
+			errors:    []string{"/id: 值不符合 oneOf 中的任何一种定义"},
+		},
+		{
+			name:      "oneOf matches two",
+			schema:    `{"type":"object","properties":{"n":{"oneOf":[{"type":"number"},{"type":"integer"}]}}}`,
+			arguments: `{"n":3}`,
+			errors:    []string{"/n: 值同时符合 oneOf 中的 2 种定义"},
+		},
+		{
+			name:      "integer accepts whole numbers",
+			schema:    `{"type":"object","properties":{"count":{"type":"integer"}}}`,
+			arguments: `{"count":3.0}`,
+		},
+		{
+			name:      "integer rejects fractions",
+			schema:    `{"type":"object","properties":{"count":{"type":"integer"}}}`,
+			arguments: `{"count":3.5}`,
+			errors:    []string{"/count: 类型应为 integer，实际为 number"},
+		},
+		{
+			name:      "number accepts integers",
+			schema:    `{"type":"object","properties":{"ratio":{"type":"number","minimum":0,"maximum":1}}}`,
+			arguments: `{"ratio":1}`,
+		},
+		{
+			name:      "union type",
+			schema:    `{"type":"object","properties":{"limit":{"type":["integer","null"]}}}`,
+			arguments: `{"limit":"10"}`,
+			errors:    []string{"/limit: 类型应为 integer 或 null，实际为 string"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := mustSchema(t, tt.schema).Validate(mustArguments(t, tt.arguments))
+			if len(errs) != len(tt.errors) {
+				t.Fatalf("Validate() = %v, want %d errors %v", errs, len(tt.errors), tt.errors)
+			}
+			for i, want := range tt.errors {
+				path, message, _ := strings.Cut(want, ": ")
+				if errs[i].Path != path || !strings.Contains(errs[i].Message, message) {
+					t.Errorf("error %d = %q, want %q", i, errs[i].Error(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestSchemaValidateGoNumbers(t *testing.T) {
+	// 部分提供者解析出的参数使用 Go 的整数类型
+	schema := mustSchema(t, `{"type":"object","properties":{"count":{"type":"integer"},"ratio":{"type":"number"}}}`)
+	if errs := schema.Validate(map[string]interface{}{"count": 3, "ratio": int64(1)}); len(errs) > 0 {
+		t.Errorf("Validate() = %v, want no errors", errs)
+	}
+	if errs := schema.Validate(map[string]interface{}{"count": 2.5}); len(errs) != 1 {
+		t.Errorf("Validate() = %v, want 1 error", errs)
+	}
+}
+
+func TestSchemaMap(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema Schema
+		want   string
+	}{
+		{
+			name:   "empty schema",
+			schema: Schema{},
+			want:   `{"properties":{},"type":"object"}`,
+		},
+		{
+			name:   "all fields",
+			schema: mustSchema(t, `{"type":"object","properties":{"item":{"$ref":"#/$defs/item"}},"required":["item"],"additionalProperties":false,"$defs":{"item":{"type":"string"}}}`),
+			want:   `{"$defs":{"item":{"type":"string"}},"additionalProperties":false,"properties":{"item":{"$ref":"#/$defs/item"}},"required":["item"],"type":"object"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.schema.Map())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("Map() = %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ==========================
+// 请求追踪
+// ==========================
+
+// Attempt 记录一次对提供者的调用结果
+type Attempt struct {
+	Provider string     `json:"provider"`        // 提供者名称
+	Error    string     `json:"error,omitempty"` // 失败时的错误信息
+	Class    ErrorClass `json:"class,omitempty"` // 失败时的错误类别
+}
+
+// Trace 记录一次对话请求中的路由决策和提供者切换情况
+// 通过 context 在控制器、路由器和组合提供者之间传递
+type Trace struct {
+	mu       sync.Mutex
+	Rule     string    `json:"rule"`     // 命中的路由规则
+	Provider string    `json:"provider"` // 路由选择的提供者
+	Reason   string    `json:"reason"`   // 选择原因
+	Attempts []Attempt `json:"attempts"` // 依次尝试的提供者
+}
+
+type traceKey struct{}
+
+// WithTrace 将 Trace 放入 context
+func WithTrace(ctx context.Context, trace *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// TraceFromContext 从 context 中获取 Trace，不存在时返回 nil
+func TraceFromContext(ctx context.Context) *Trace {
+	trace, _ := ctx.Value(traceKey{}).(*Trace)
+	return trace
+}
+
+// SetDecision 记录路由决策
+func (t *Trace) SetDecision(rule, provider, reason string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Rule, t.Provider, t.Reason = rule, provider, reason
+}
+
+// AddAttempt 记录一次提供者调用
+func (t *Trace) AddAttempt(attempt Attempt) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Attempts = append(t.Attempts, attempt)
+}
+
+// Fallbacks 返回失败后被切换掉的提供者，格式为 provider(class)
+func (t *Trace) Fallbacks() []string {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var result []string
+	for _, attempt := range t.Attempts {
+		if attempt.Error != "" {
+			result = append(result, fmt.Sprintf("%s(%s)", attempt.Provider, attempt.Class))
+		}
+	}
+	return result
+}
+
+// String 返回便于写入日志或响应头的摘要
+func (t *Trace) String() string {
+	if t == nil {
+		return ""
+	}
+	fallbacks := t.Fallbacks()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return fmt.Sprintf("rule=%s provider=%s fallbacks=[%s]", t.Rule, t.Provider, strings.Join(fallbacks, ","))
+}
@@ -0,0 +1,172 @@
+package mcpserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrApprovalNotFound = errors.New("审批请求不存在或已经结束")
+	ErrApprovalTimeout  = errors.New("等待审批超时")
+	ErrNoApprover       = errors.New("当前请求没有可以审批的用户")
+)
+
+// Approval 一个等待用户审批的操作
+type Approval struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`            // 操作类型：sampling 或 tool_call
+	Server    string    `json:"server"`          // 发起操作的 MCP 服务器
+	Owner     string    `json:"owner,omitempty"` // 负责审批的用户 ID，为空时由管理员审批
+	Summary   string    `json:"summary"`         // 展示给用户的简短说明
+	Detail    any       `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Decision 用户对审批请求的处理结果
+type Decision struct {
+	Approved  bool           `json:"approved"`
+	Arguments map[string]any `json:"arguments,omitempty"` // 用户修改后的工具参数，只用于工具调用，为空时使用原来的参数
+	Reason    string         `json:"reason,omitempty"`    // 拒绝的原因，会告诉模型
+}
+
+// pendingApproval 等待中的审批，decision 只会写入一次
+type pendingApproval struct {
+	Approval
+	decision chan Decision
+}
+
+// Approvals 管理等待用户审批的操作：发起方阻塞在 Request 中，直到用户通过 Decide 给出结果或者超时
+type Approvals struct {
+	timeout time.Duration
+	mu      sync.Mutex
+	pending map[string]*pendingApproval
+}
+
+// NewApprovals 创建审批管理器，timeout 为等待审批的最长时间
+func NewApprovals(timeout time.Duration) *Approvals {
+	return &Approvals{
+		timeout: timeout,
+		pending: make(map[string]*pendingApproval),
+	}
+}
+
+// Request 发起审批并等待用户的处理结果
+// pending 不为 nil 时，在审批请求可以被处理之后、开始等待之前调用，用于把审批 ID 等信息通知给用户。
+// 超时返回 ErrApprovalTimeout，ctx 结束时返回 ctx 的错误
+func (a *Approvals) Request(ctx context.Context, approval Approval, pending func(Approval)) (Decision, error) {
+	now := time.Now()
+	approval.ID = newApprovalID()
+	approval.CreatedAt = now
+	approval.ExpiresAt = now.Add(a.timeout)
+	p := &pendingApproval{Approval: approval, decision: make(chan Decision, 1)}
+
+	a.mu.Lock()
+	a.pending[approval.ID] = p
+	a.mu.Unlock()
+	if pending != nil {
+		pending(approval)
+	}
+	defer func() {
+		a.mu.Lock()
+		delete(a.pending, approval.ID)
+		a.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(a.timeout)
+	defer timer.Stop()
+	select {
+	case decision := <-p.decision:
+		return decision, nil
+	case <-timer.C:
+		return Decision{}, ErrApprovalTimeout
+	case <-ctx.Done():
+		return Decision{}, ctx.Err()
+	}
+}
+
+// Pending 返回用户可以审批的请求，按创建时间排序
+// 管理员还可以看到没有指定审批人的请求
+func (a *Approvals) Pending(userID string, admin bool) []Approval {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	approvals := []Approval{}
+	for _, p := range a.pending {
+		if p.visibleTo(userID, admin) {
+			approvals = append(approvals, p.Approval)
+		}
+	}
+	sort.Slice(approvals, func(i, j int) bool {
+		return approvals[i].CreatedAt.Before(approvals[j].CreatedAt)
+	})
+	return approvals
+}
+
+// Decide 同意或拒绝一个审批请求
+func (a *Approvals) Decide(id, userID string, admin bool, decision Decision) error {
+	a.mu.Lock()
+	p, ok := a.pending[id]
+	if ok && p.visibleTo(userID, admin) {
+		// 从 pending 中删除，保证结果只写入一次
+		delete(a.pending, id)
+	} else {
+		ok = false
+	}
+	a.mu.Unlock()
+	if !ok {
+		return ErrApprovalNotFound
+	}
+	p.decision <- decision
+	return nil
+}
+
+// visibleTo 判断用户是否可以审批这个请求
+func (p *pendingApproval) visibleTo(userID string, admin bool) bool {
+	if p.Owner == "" {
+		return admin
+	}
+	return p.Owner == userID
+}
+
+type approverKey struct{}
+
+// approver 一次对话请求中负责审批工具调用的用户
+type approver struct {
+	approvals *Approvals
+	owner     string
+}
+
+// WithApprover 返回携带审批管理器和审批用户的 ctx，对话中需要确认的工具调用由这个用户审批
+func WithApprover(ctx context.Context, approvals *Approvals, owner string) context.Context {
+	return context.WithValue(ctx, approverKey{}, approver{approvals: approvals, owner: owner})
+}
+
+// RequestApproval 由 ctx 中的审批用户审批，approval.Owner 被设置为这个用户
+// ctx 中没有审批用户时返回 ErrNoApprover
+func RequestApproval(ctx context.Context, approval Approval, pending func(Approval)) (Decision, error) {
+	a, ok := ctx.Value(approverKey{}).(approver)
+	if !ok || a.approvals == nil || a.owner == "" {
+		return Decision{}, ErrNoApprover
+	}
+	approval.Owner = a.owner
+	return a.approvals.Request(ctx, approval, pending)
+}
+
+// ApproverFromContext 返回 ctx 中的审批用户，没有时返回空字符串
+func ApproverFromContext(ctx context.Context) string {
+	a, _ := ctx.Value(approverKey{}).(approver)
+	return a.owner
+}
+
+// newApprovalID 生成随机的审批 ID
+func newApprovalID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "apr_" + hex.EncodeToString(b)
+}
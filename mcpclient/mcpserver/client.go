@@ -0,0 +1,281 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	"mcpclient/models"
+)
+
+// 连接 MCP 服务器时初始化的超时时间
+const initializeTimeout = 30 * time.Second
+
+// Client MCP 客户端的通用接口，屏蔽 SSE、Streamable HTTP、stdio 等传输方式的差异
+// 调用方只通过这个接口调用工具，不需要关心服务器是远程服务还是本地子进程
+type Client interface {
+	client.MCPClient
+
+	// Name 返回服务器在配置中的名称，也是工具名称的命名空间
+	Name() string
+
+	// Transport 返回服务器的传输方式，例如 sse、streamable-http、stdio
+	Transport() string
+
+	// Stderr 返回 stdio 子进程最近输出到 stderr 的内容，其他传输方式返回 nil
+	Stderr() []string
+
+	// SessionID 返回 streamable-http 方式下服务器分配的会话 ID，其他传输方式返回空字符串
+	SessionID() string
+
+	// GetServerCapabilities 返回服务器在初始化时声明的能力
+	GetServerCapabilities() mcp.ServerCapabilities
+
+	// Observe 注册工具调用期间的进度和日志回调，返回请求中需要携带的进度 token 和取消注册的函数
+	Observe(observer Observer) (mcp.ProgressToken, func())
+
+	// ToolTimeout 返回服务器单独配置的工具调用超时时间，没有配置时返回 0
+	ToolTimeout() time.Duration
+
+	// SerialTool 判断工具是否被配置为不能和同一服务器的其他工具调用并行执行
+	SerialTool(name string) bool
+}
+
+// serverClient 基于 mcp-go 客户端实现 Client 接口
+type serverClient struct {
+	*client.Client
+	config models.MCPServerConfig
+	stderr *lineBuffer // 只有 stdio 传输方式才有
+
+	sessionMu sync.Mutex // 避免并发的请求在会话失效时重复建立会话
+	observers observers  // 正在进行的工具调用的进度和日志回调
+}
+
+// Name 返回服务器的名称
+func (c *serverClient) Name() string {
+	return c.config.Name
+}
+
+// Transport 返回服务器的传输方式
+func (c *serverClient) Transport() string {
+	return c.config.GetTransport()
+}
+
+// Stderr 返回 stdio 子进程最近的 stderr 输出
+func (c *serverClient) Stderr() []string {
+	if c.stderr == nil {
+		return nil
+	}
+	return c.stderr.Lines()
+}
+
+// SessionID 返回当前的会话 ID
+func (c *serverClient) SessionID() string {
+	return c.GetSessionId()
+}
+
+// ToolTimeout 返回服务器单独配置的工具调用超时时间
+func (c *serverClient) ToolTimeout() time.Duration {
+	return c.config.GetToolTimeout()
+}
+
+// SerialTool 判断工具是否不能并行执行
+func (c *serverClient) SerialTool(name string) bool {
+	return c.config.IsSerialTool(name)
+}
+
+// ListTools 获取工具列表，会话失效时重新建立会话后重试
+func (c *serverClient) ListTools(ctx context.Context, request mcp.ListToolsRequest) (*mcp.ListToolsResult, error) {
+	return withSession(ctx, c, func() (*mcp.ListToolsResult, error) {
+		return c.Client.ListTools(ctx, request)
+	})
+}
+
+// ListResources 获取资源列表，会话失效时重新建立会话后重试
+func (c *serverClient) ListResources(ctx context.Context, request mcp.ListResourcesRequest) (*mcp.ListResourcesResult, error) {
+	return withSession(ctx, c, func() (*mcp.ListResourcesResult, error) {
+		return c.Client.ListResources(ctx, request)
+	})
+}
+
+// ListResourceTemplates 获取资源模板列表，会话失效时重新建立会话后重试
+func (c *serverClient) ListResourceTemplates(ctx context.Context, request mcp.ListResourceTemplatesRequest) (*mcp.ListResourceTemplatesResult, error) {
+	return withSession(ctx, c, func() (*mcp.ListResourceTemplatesResult, error) {
+		return c.Client.ListResourceTemplates(ctx, request)
+	})
+}
+
+// ReadResource 读取资源，会话失效时重新建立会话后重试
+func (c *serverClient) ReadResource(ctx context.Context, request mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	return withSession(ctx, c, func() (*mcp.ReadResourceResult, error) {
+		return c.Client.ReadResource(ctx, request)
+	})
+}
+
+// ListPrompts 获取提示模板列表，会话失效时重新建立会话后重试
+func (c *serverClient) ListPrompts(ctx context.Context, request mcp.ListPromptsRequest) (*mcp.ListPromptsResult, error) {
+	return withSession(ctx, c, func() (*mcp.ListPromptsResult, error) {
+		return c.Client.ListPrompts(ctx, request)
+	})
+}
+
+// GetPrompt 获取展开后的提示消息，会话失效时重新建立会话后重试
+func (c *serverClient) GetPrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	return withSession(ctx, c, func() (*mcp.GetPromptResult, error) {
+		return c.Client.GetPrompt(ctx, request)
+	})
+}
+
+// CallTool 调用工具，会话失效时重新建立会话后重试
+func (c *serverClient) CallTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return withSession(ctx, c, func() (*mcp.CallToolResult, error) {
+		return c.Client.CallTool(ctx, request)
+	})
+}
+
+// Connect 根据配置创建 MCP 客户端，启动连接（stdio 方式会启动子进程）并完成初始化
+// ctx 只用于控制初始化的超时，连接本身的生命周期由 Close 控制；opts 用于设置采样等客户端能力。
+// config 中的 ${env:NAME}、${secret:NAME} 引用需要事先解析
+func Connect(ctx context.Context, config models.MCPServerConfig, opts ...client.ClientOption) (Client, error) {
+	var c *serverClient
+	var err error
+	switch config.GetTransport() {
+	case models.TransportSSE:
+		c, err = newSSEClient(config, opts)
+	case models.TransportStreamableHTTP:
+		c, err = newStreamableHTTPClient(config, opts)
+	case models.TransportStdio:
+		c, err = newStdioClient(config, opts)
+	default:
+		return nil, fmt.Errorf("MCP 服务器 %s 的传输方式 %s 不受支持", config.Name, config.Transport)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.OnNotification(c.dispatchNotification)
+
+	if err := c.initialize(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate 检查服务器配置：名称不能为空、不能重复，也不能包含工具命名空间的分隔符 "__"，
+// 传输方式必须受支持，并且配置了对应的 url 或 command；采样策略必须受支持，sse 传输方式不支持采样
+func Validate(configs []models.MCPServerConfig) error {
+	names := make(map[string]bool, len(configs))
+	for _, config := range configs {
+		if config.Name == "" {
+			return fmt.Errorf("MCP 服务器的名称不能为空")
+		}
+		if strings.Contains(config.Name, "__") {
+			return fmt.Errorf("MCP 服务器的名称 %s 不能包含 __", config.Name)
+		}
+		if names[config.Name] {
+			return fmt.Errorf("MCP 服务器的名称 %s 重复", config.Name)
+		}
+		names[config.Name] = true
+
+		switch config.GetTransport() {
+		case models.TransportSSE, models.TransportStreamableHTTP:
+			if config.MCPServerURL == "" {
+				return fmt.Errorf("MCP 服务器 %s 没有配置 url", config.Name)
+			}
+		case models.TransportStdio:
+			if config.Command == "" {
+				return fmt.Errorf("MCP 服务器 %s 没有配置 command", config.Name)
+			}
+		default:
+			return fmt.Errorf("MCP 服务器 %s 的传输方式 %s 不受支持", config.Name, config.Transport)
+		}
+
+		if err := validateAuth(config); err != nil {
+			return err
+		}
+		if config.ToolTimeout != "" {
+			if timeout, err := time.ParseDuration(config.ToolTimeout); err != nil || timeout <= 0 {
+				return fmt.Errorf("MCP 服务器 %s 的工具超时时间 %s 无效", config.Name, config.ToolTimeout)
+			}
+		}
+		for _, pattern := range config.SerialTools {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("MCP 服务器 %s 的 serial_tools 中 %s 格式错误", config.Name, pattern)
+			}
+		}
+		if !ValidLogLevel(config.GetLogLevel()) {
+			return fmt.Errorf("MCP 服务器 %s 的日志级别 %s 不受支持", config.Name, config.LogLevel)
+		}
+
+		switch config.GetSampling() {
+		case models.SamplingDeny:
+		case models.SamplingAllow, models.SamplingApprove:
+			// 旧版 SSE 协议不能由服务器向客户端发送请求
+			if config.GetTransport() == models.TransportSSE {
+				return fmt.Errorf("MCP 服务器 %s 的传输方式 sse 不支持采样", config.Name)
+			}
+		default:
+			return fmt.Errorf("MCP 服务器 %s 的采样策略 %s 不受支持", config.Name, config.Sampling)
+		}
+	}
+	return nil
+}
+
+// newSSEClient 创建 SSE 客户端并建立连接
+func newSSEClient(config models.MCPServerConfig, opts []client.ClientOption) (*serverClient, error) {
+	if config.MCPServerURL == "" {
+		return nil, fmt.Errorf("MCP 服务器 %s 没有配置 url", config.Name)
+	}
+	sseTransport, err := transport.NewSSE(config.MCPServerURL+"/sse",
+		transport.WithHeaders(httpHeaders(config)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("创建 MCP 服务器 %s 的客户端失败: %w", config.Name, err)
+	}
+	mcpClient := client.NewClient(sseTransport, opts...)
+	// SSE 连接在 Start 的 ctx 取消后断开，因此使用不会取消的 ctx
+	if err := mcpClient.Start(context.Background()); err != nil {
+		return nil, fmt.Errorf("连接 MCP 服务器 %s 失败: %w", config.Name, err)
+	}
+	return &serverClient{Client: mcpClient, config: config}, nil
+}
+
+// initialize 完成 MCP 初始化握手
+func (c *serverClient) initialize(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, initializeTimeout)
+	defer cancel()
+
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{
+		Name:    "mcpclient",
+		Version: "1.0.0",
+	}
+	result, err := c.Initialize(ctx, initRequest)
+	if err != nil {
+		return fmt.Errorf("初始化 MCP 服务器 %s 失败: %w", c.config.Name, err)
+	}
+	log.Info("MCP 服务器已连接",
+		"server", c.config.Name,
+		"transport", c.Transport(),
+		"server_name", result.ServerInfo.Name,
+		"server_version", result.ServerInfo.Version,
+		"session_id", c.SessionID())
+
+	// 日志级别属于会话，每次建立会话后都需要设置
+	if result.Capabilities.Logging != nil {
+		levelRequest := mcp.SetLevelRequest{}
+		levelRequest.Params.Level = mcp.LoggingLevel(c.config.GetLogLevel())
+		if err := c.SetLevel(ctx, levelRequest); err != nil {
+			log.Warn("设置 MCP 服务器日志级别失败", "server", c.config.Name, "error", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package mcpserver
+
+import (
+	"context"
+	"time"
+)
+
+// ToolExecution 一轮对话中工具调用的执行参数
+type ToolExecution struct {
+	Concurrency int           // 模型在一条消息中发起多个工具调用时，同时执行的数量上限，默认 4
+	Timeout     time.Duration // 一次工具调用的超时时间，不包括等待用户确认的时间，默认 5 分钟；服务器可以单独配置
+}
+
+// withDefaults 返回补充了默认值的参数
+func (e ToolExecution) withDefaults() ToolExecution {
+	if e.Concurrency <= 0 {
+		e.Concurrency = 4
+	}
+	if e.Timeout <= 0 {
+		e.Timeout = 5 * time.Minute
+	}
+	return e
+}
+
+type toolExecutionKey struct{}
+
+// WithToolExecution 返回携带工具调用执行参数的 ctx
+func WithToolExecution(ctx context.Context, execution ToolExecution) context.Context {
+	return context.WithValue(ctx, toolExecutionKey{}, execution)
+}
+
+// ToolExecutionFromContext 返回 ctx 中的工具调用执行参数，没有设置的项使用默认值
+func ToolExecutionFromContext(ctx context.Context) ToolExecution {
+	execution, _ := ctx.Value(toolExecutionKey{}).(ToolExecution)
+	return execution.withDefaults()
+}
@@ -0,0 +1,58 @@
+package mcpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"mcpclient/models"
+)
+
+// newStreamableHTTPClient 创建 Streamable HTTP 客户端
+// 会话 ID 由服务器在初始化时通过 Mcp-Session-Id 响应头分配，之后的请求都会带上它；
+// 同时保持一个 GET 长连接，用于接收服务器在没有请求时主动发送的通知
+func newStreamableHTTPClient(config models.MCPServerConfig, opts []client.ClientOption) (*serverClient, error) {
+	if config.MCPServerURL == "" {
+		return nil, fmt.Errorf("MCP 服务器 %s 没有配置 url", config.Name)
+	}
+	httpTransport, err := transport.NewStreamableHTTP(config.MCPServerURL,
+		transport.WithContinuousListening(),
+		transport.WithHTTPHeaders(httpHeaders(config)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("创建 MCP 服务器 %s 的客户端失败: %w", config.Name, err)
+	}
+	mcpClient := client.NewClient(httpTransport, opts...)
+	// 通知长连接在 Start 的 ctx 取消后断开，因此使用不会取消的 ctx
+	if err := mcpClient.Start(context.Background()); err != nil {
+		return nil, fmt.Errorf("连接 MCP 服务器 %s 失败: %w", config.Name, err)
+	}
+	return &serverClient{Client: mcpClient, config: config}, nil
+}
+
+// withSession 执行请求，服务器终止了会话（streamable-http 返回 404）时重新初始化得到新的会话，然后重试一次
+// 其他传输方式不会返回 ErrSessionTerminated，因此直接返回请求的结果
+func withSession[T any](ctx context.Context, c *serverClient, call func() (T, error)) (T, error) {
+	sessionID := c.SessionID()
+	result, err := call()
+	if !errors.Is(err, transport.ErrSessionTerminated) {
+		return result, err
+	}
+
+	c.sessionMu.Lock()
+	// 其他请求可能已经建立了新的会话
+	if current := c.SessionID(); current == "" || current == sessionID {
+		log.Warn("MCP 会话已失效，正在重新建立会话",
+			"server", c.config.Name,
+			"session_id", sessionID)
+		if err := c.initialize(ctx); err != nil {
+			c.sessionMu.Unlock()
+			return result, err
+		}
+	}
+	c.sessionMu.Unlock()
+	return call()
+}
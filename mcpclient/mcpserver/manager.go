@@ -0,0 +1,606 @@
+package mcpserver
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"mcpclient/models"
+)
+
+// 服务器的连接状态
+const (
+	StateConnecting = "connecting" // 正在连接，还没有可用的连接
+	StateHealthy    = "healthy"    // 连接正常，工具可以提供给模型
+	StateUnhealthy  = "unhealthy"  // 连接失败或者健康检查失败，正在重连
+)
+
+// ManagerOptions 健康检查和重连的参数，为零的字段使用默认值
+type ManagerOptions struct {
+	HealthInterval time.Duration // 健康检查（ping）的间隔，默认 30 秒
+	PingTimeout    time.Duration // 一次 ping 的超时时间，默认 10 秒
+	MinBackoff     time.Duration // 重连的初始等待时间，默认 1 秒
+	MaxBackoff     time.Duration // 重连的最长等待时间，默认 60 秒
+
+	Sampler         Sampler       // 执行服务器的采样请求，为 nil 时所有服务器都不支持采样
+	ApprovalTimeout time.Duration // 等待用户审批的最长时间，默认 5 分钟
+	SecretsFile     string        // 服务器配置中 ${secret:NAME} 引用的密钥文件
+	Admins          []string      // 管理员的用户 ID，负责审批无法确定用户的采样请求
+
+	ToolConcurrency int           // 一轮中同时执行的工具调用数量上限，默认 4
+	ToolTimeout     time.Duration // 一次工具调用的超时时间，默认 5 分钟
+}
+
+// withDefaults 返回补充了默认值的参数
+func (o ManagerOptions) withDefaults() ManagerOptions {
+	if o.HealthInterval <= 0 {
+		o.HealthInterval = 30 * time.Second
+	}
+	if o.PingTimeout <= 0 {
+		o.PingTimeout = 10 * time.Second
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = time.Second
+	}
+	if o.MaxBackoff < o.MinBackoff {
+		o.MaxBackoff = 60 * time.Second
+	}
+	if o.ApprovalTimeout <= 0 {
+		o.ApprovalTimeout = 5 * time.Minute
+	}
+	return o
+}
+
+// ServerStatus 一个 MCP 服务器的运行状态
+type ServerStatus struct {
+	Name          string    `json:"name"`
+	Transport     string    `json:"transport"`
+	State         string    `json:"state"`
+	ToolCount     int       `json:"tool_count"`
+	ResourceCount int       `json:"resource_count"`
+	PromptCount   int       `json:"prompt_count"`
+	Error         string    `json:"error,omitempty"`        // 最近一次连接或健康检查的错误
+	ConnectedAt   time.Time `json:"connected_at,omitempty"` // 当前连接建立的时间
+	LastCheck     time.Time `json:"last_check,omitempty"`   // 最近一次健康检查的时间
+	Reconnects    int       `json:"reconnects"`             // 启动以来重新连接的次数
+	SessionID     string    `json:"session_id,omitempty"`   // streamable-http 方式的会话 ID
+	Stderr        []string  `json:"stderr,omitempty"`       // stdio 子进程最近的 stderr 输出，接口只返回给管理员
+}
+
+// managedServer Manager 中的一个服务器，字段由 Manager.mu 保护
+type managedServer struct {
+	config models.MCPServerConfig
+	client *lease     // 只在 healthy 状态下不为 nil
+	tools  []mcp.Tool // 最近一次获取到的工具列表
+	status ServerStatus
+
+	resources       []mcp.Resource                    // 最近一次获取到的资源列表
+	templates       []mcp.ResourceTemplate            // 最近一次获取到的资源模板列表
+	contents        map[string][]mcp.ResourceContents // 已读取的资源内容缓存，key 为资源 URI，重新连接后清空
+	subscribed      map[string]bool                   // 当前连接已经订阅更新的资源 URI
+	subscribeFailed bool                              // 当前连接订阅失败过，不再尝试订阅，资源也不再缓存
+	contentVersion  uint64                            // 每次资源更新时加一，避免把读取期间已经过期的内容写入缓存
+	prompts         []mcp.Prompt                      // 最近一次获取到的提示模板列表
+
+	cancel context.CancelFunc // 停止这个服务器的健康检查和重连
+	done   chan struct{}      // 服务器的 goroutine 结束后关闭
+}
+
+// lease 带引用计数的客户端，字段由 Manager.mu 保护
+// 服务器被移除、修改或者健康检查失败后客户端被淘汰，等到所有引用它的请求结束后才真正关闭，
+// 这样重新加载配置不会中断正在进行的对话
+type lease struct {
+	client  Client
+	refs    int
+	retired bool
+}
+
+// Snapshot 某一时刻所有健康服务器的客户端和工具，同一个快照中的客户端和工具总是一致的
+// 使用完后必须调用 Release
+type Snapshot struct {
+	Clients map[string]Client     // key 为服务器名称
+	Tools   map[string][]mcp.Tool // key 为服务器名称
+
+	manager *Manager
+	leases  []*lease
+	once    sync.Once
+}
+
+// Release 释放快照引用的客户端，已经被淘汰的客户端在最后一个引用释放后关闭
+func (s *Snapshot) Release() {
+	s.once.Do(func() {
+		s.manager.release(s.leases...)
+	})
+}
+
+// release 减少客户端的引用计数
+func (m *Manager) release(leases ...*lease) {
+	var closing []Client
+	m.mu.Lock()
+	for _, l := range leases {
+		l.refs--
+		if l.retired && l.refs == 0 {
+			closing = append(closing, l.client)
+		}
+	}
+	m.mu.Unlock()
+	for _, c := range closing {
+		c.Close()
+	}
+}
+
+// Manager 长期运行的 MCP 客户端管理器
+// 每个服务器独立连接，互不影响；连接后定期 ping，失败时关闭连接并按指数退避重连，
+// 只有 healthy 状态的服务器的客户端和工具会通过 Acquire 提供给模型。
+// 服务器列表可以通过 Reload 在运行时修改
+type Manager struct {
+	opts     ManagerOptions
+	mu       sync.Mutex
+	servers  []*managedServer // 按配置顺序排列
+	reloadMu sync.Mutex       // 保证同一时间只有一个 Reload
+
+	approvals *Approvals // 等待用户审批的操作
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewManager 根据配置创建管理器，调用 Start 后才会开始连接
+func NewManager(configs []models.MCPServerConfig, opts ManagerOptions) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	opts = opts.withDefaults()
+	m := &Manager{
+		opts:      opts,
+		approvals: NewApprovals(opts.ApprovalTimeout),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	for _, config := range configs {
+		m.servers = append(m.servers, newManagedServer(config))
+	}
+	return m
+}
+
+// newManagedServer 创建一个还没有启动的服务器
+func newManagedServer(config models.MCPServerConfig) *managedServer {
+	return &managedServer{
+		config: config,
+		status: ServerStatus{
+			Name:      config.Name,
+			Transport: config.GetTransport(),
+			State:     StateConnecting,
+		},
+	}
+}
+
+// Start 在后台启动所有服务器，等待每个服务器完成第一次连接尝试（无论成功与否）或者 ctx 结束后返回
+// 连接失败的服务器会在后台继续重连，不会影响其他服务器
+func (m *Manager) Start(ctx context.Context) {
+	m.mu.Lock()
+	servers := m.servers
+	m.mu.Unlock()
+	m.startServers(ctx, servers)
+}
+
+// Reload 用新的配置替换服务器列表：新增的服务器开始连接，删除的服务器停止，配置有变化的服务器重新连接，
+// 没有变化的服务器保持原来的连接。新服务器完成第一次连接尝试（或者 ctx 结束）后，服务器列表一次性切换，
+// 之后的请求才会看到新的工具列表；旧的客户端在正在进行的请求结束后才关闭
+func (m *Manager) Reload(ctx context.Context, configs []models.MCPServerConfig) error {
+	if err := Validate(configs); err != nil {
+		return err
+	}
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	m.mu.Lock()
+	current := make(map[string]*managedServer, len(m.servers))
+	for _, s := range m.servers {
+		current[s.config.Name] = s
+	}
+	m.mu.Unlock()
+
+	servers := make([]*managedServer, 0, len(configs))
+	var started []*managedServer
+	for _, config := range configs {
+		if s, ok := current[config.Name]; ok && reflect.DeepEqual(s.config, config) {
+			servers = append(servers, s)
+			delete(current, config.Name)
+			continue
+		}
+		s := newManagedServer(config)
+		servers = append(servers, s)
+		started = append(started, s)
+	}
+	m.startServers(ctx, started)
+
+	// current 中剩下的是被删除或者被替换的服务器
+	m.mu.Lock()
+	m.servers = servers
+	m.mu.Unlock()
+	for _, s := range current {
+		m.stopServer(s)
+	}
+
+	// 配置没有变化时（例如文件监听读到了管理接口刚写入的配置）所有连接都保持不变
+	if len(started) == 0 && len(current) == 0 {
+		log.Debug("MCP 服务器配置没有变化", "servers", len(servers))
+		return nil
+	}
+	log.Info("MCP 服务器配置已重新加载",
+		"servers", len(servers),
+		"started", len(started),
+		"stopped", len(current))
+	return nil
+}
+
+// Close 停止所有服务器的健康检查和重连，并关闭所有连接
+func (m *Manager) Close() {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	m.cancel()
+	m.mu.Lock()
+	servers := m.servers
+	m.mu.Unlock()
+	for _, s := range servers {
+		m.stopServer(s)
+	}
+}
+
+// Acquire 返回所有 healthy 服务器的客户端和工具列表的快照
+func (m *Manager) Acquire() *Snapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := &Snapshot{
+		Clients: make(map[string]Client),
+		Tools:   make(map[string][]mcp.Tool),
+		manager: m,
+	}
+	for _, s := range m.servers {
+		if s.status.State != StateHealthy {
+			continue
+		}
+		s.client.refs++
+		snapshot.leases = append(snapshot.leases, s.client)
+		snapshot.Clients[s.config.Name] = s.client.client
+		snapshot.Tools[s.config.Name] = s.tools
+	}
+	return snapshot
+}
+
+// ToolExecution 返回配置的工具调用执行参数，对话请求通过 WithToolExecution 使用
+func (m *Manager) ToolExecution() ToolExecution {
+	return ToolExecution{
+		Concurrency: m.opts.ToolConcurrency,
+		Timeout:     m.opts.ToolTimeout,
+	}.withDefaults()
+}
+
+// Approvals 返回等待用户审批的操作
+func (m *Manager) Approvals() *Approvals {
+	return m.approvals
+}
+
+// Status 按配置顺序返回所有服务器的状态
+func (m *Manager) Status() []ServerStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statuses := make([]ServerStatus, 0, len(m.servers))
+	for _, s := range m.servers {
+		status := s.status
+		if s.client != nil {
+			status.SessionID = s.client.client.SessionID()
+			status.Stderr = s.client.client.Stderr()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// startServers 为每个服务器启动 goroutine，等待它们完成第一次连接尝试或者 ctx 结束
+func (m *Manager) startServers(ctx context.Context, servers []*managedServer) {
+	var ready sync.WaitGroup
+	for _, s := range servers {
+		serverCtx, cancel := context.WithCancel(m.ctx)
+		s.cancel = cancel
+		s.done = make(chan struct{})
+		ready.Add(1)
+		go func() {
+			defer close(s.done)
+			m.run(serverCtx, s, ready.Done)
+		}()
+	}
+
+	allReady := make(chan struct{})
+	go func() {
+		ready.Wait()
+		close(allReady)
+	}()
+	select {
+	case <-allReady:
+	case <-ctx.Done():
+	}
+}
+
+// stopServer 停止服务器的 goroutine 并等待它结束，客户端随之被淘汰
+func (m *Manager) stopServer(s *managedServer) {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
+
+// run 一个服务器的生命周期：连接 -> 健康检查 -> 失败后退避重连，直到 ctx 结束
+// ready 在第一次连接尝试结束后调用
+func (m *Manager) run(ctx context.Context, s *managedServer, ready func()) {
+	backoff := m.opts.MinBackoff
+	for attempt := 0; ; attempt++ {
+		events, err := m.connect(ctx, s, attempt > 0)
+		if attempt == 0 {
+			ready()
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Error("连接 MCP 服务器失败",
+				"server", s.config.Name,
+				"retry_in", backoff,
+				"error", err)
+			if !sleep(ctx, backoff) {
+				return
+			}
+			backoff = min(backoff*2, m.opts.MaxBackoff)
+			continue
+		}
+
+		backoff = m.opts.MinBackoff
+		m.monitor(ctx, s, events)
+		if ctx.Err() != nil {
+			m.disconnect(s, ctx.Err())
+			return
+		}
+	}
+}
+
+// serverEvents 服务器通知转换成的信号，由 monitor 处理
+// 通知在传输层读取响应的 goroutine 中回调，在回调中发送请求会阻塞读取，因此回调只发送信号
+type serverEvents struct {
+	toolsChanged     chan struct{} // tools/list_changed
+	resourcesChanged chan struct{} // resources/list_changed
+	promptsChanged   chan struct{} // prompts/list_changed
+}
+
+// notify 发送信号，已经有未处理的信号时忽略
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// connect 连接服务器并获取工具、资源和提示模板列表，成功后把服务器标记为 healthy
+// 返回的 serverEvents 在服务器发送列表变化的通知后收到信号
+func (m *Manager) connect(ctx context.Context, s *managedServer, reconnect bool) (*serverEvents, error) {
+	m.mu.Lock()
+	if reconnect {
+		s.status.Reconnects++
+	}
+	m.mu.Unlock()
+
+	log.Debug("正在连接 MCP 服务器", "server", s.config.Name, "config", s.config.Redacted())
+	// 每次连接时解析密钥，环境变量或密钥文件修改后重新连接即可生效
+	resolved, err := resolveConfig(s.config, m.opts.SecretsFile)
+	if err != nil {
+		m.setError(s, err)
+		return nil, err
+	}
+
+	var opts []client.ClientOption
+	var sampling *samplingHandler
+	// 只有允许采样时才设置处理函数，客户端在初始化时才会声明采样能力
+	if m.opts.Sampler != nil && s.config.GetSampling() != models.SamplingDeny {
+		sampling = &samplingHandler{
+			config:    s.config,
+			sampler:   m.opts.Sampler,
+			approvals: m.approvals,
+			admins:    len(m.opts.Admins) > 0,
+		}
+		opts = append(opts, client.WithSamplingHandler(sampling))
+	}
+	mcpClient, err := Connect(ctx, resolved, opts...)
+	if err != nil {
+		m.setError(s, err)
+		return nil, err
+	}
+	if sc, ok := mcpClient.(*serverClient); ok && sampling != nil {
+		sampling.client.Store(sc)
+	}
+
+	// 在获取列表之前注册，获取期间发生的变化也不会丢失
+	events := &serverEvents{
+		toolsChanged:     make(chan struct{}, 1),
+		resourcesChanged: make(chan struct{}, 1),
+		promptsChanged:   make(chan struct{}, 1),
+	}
+	mcpClient.OnNotification(func(notification mcp.JSONRPCNotification) {
+		switch notification.Method {
+		case mcp.MethodNotificationToolsListChanged:
+			notify(events.toolsChanged)
+		case mcp.MethodNotificationResourcesListChanged:
+			notify(events.resourcesChanged)
+		case mcp.MethodNotificationPromptsListChanged:
+			notify(events.promptsChanged)
+		case mcp.MethodNotificationResourceUpdated:
+			uri, _ := notification.Params.AdditionalFields["uri"].(string)
+			m.invalidateResource(s, uri)
+		}
+	})
+
+	// 只提供资源的服务器没有工具
+	toolsResult := &mcp.ListToolsResult{}
+	if mcpClient.GetServerCapabilities().Tools != nil {
+		listCtx, cancel := context.WithTimeout(ctx, initializeTimeout)
+		toolsResult, err = mcpClient.ListTools(listCtx, mcp.ListToolsRequest{})
+		cancel()
+		if err != nil {
+			mcpClient.Close()
+			m.setError(s, err)
+			return nil, err
+		}
+	}
+	// 资源和提示模板是可选功能，获取失败不影响工具的使用
+	resources, templates := listResources(ctx, s.config.Name, mcpClient)
+	prompts := listPrompts(ctx, s.config.Name, mcpClient)
+
+	now := time.Now()
+	m.mu.Lock()
+	s.client = &lease{client: mcpClient}
+	s.tools = toolsResult.Tools
+	s.resources = resources
+	s.templates = templates
+	s.contents = make(map[string][]mcp.ResourceContents)
+	s.subscribed = make(map[string]bool)
+	s.subscribeFailed = false
+	s.prompts = prompts
+	s.status.State = StateHealthy
+	s.status.ToolCount = len(toolsResult.Tools)
+	s.status.ResourceCount = len(resources)
+	s.status.PromptCount = len(prompts)
+	s.status.Error = ""
+	s.status.ConnectedAt = now
+	s.status.LastCheck = now
+	m.mu.Unlock()
+
+	log.Info("工具加载成功",
+		"server", s.config.Name,
+		"transport", mcpClient.Transport(),
+		"tools", len(toolsResult.Tools),
+		"resources", len(resources),
+		"prompts", len(prompts))
+	return events, nil
+}
+
+// monitor 定期 ping 服务器，ping 失败或者 ctx 结束时返回；ping 失败时断开连接
+// 收到列表变化的信号时重新获取对应的列表
+func (m *Manager) monitor(ctx context.Context, s *managedServer, events *serverEvents) {
+	ticker := time.NewTicker(m.opts.HealthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-events.toolsChanged:
+			m.refreshTools(ctx, s)
+			continue
+		case <-events.resourcesChanged:
+			m.refreshResources(ctx, s)
+			continue
+		case <-events.promptsChanged:
+			m.refreshPrompts(ctx, s)
+			continue
+		case <-ticker.C:
+		}
+
+		m.mu.Lock()
+		mcpClient := s.client.client
+		m.mu.Unlock()
+
+		pingCtx, cancel := context.WithTimeout(ctx, m.opts.PingTimeout)
+		err := mcpClient.Ping(pingCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Warn("MCP 服务器健康检查失败，正在重新连接",
+				"server", s.config.Name,
+				"error", err)
+			m.disconnect(s, err)
+			return
+		}
+
+		m.mu.Lock()
+		s.status.LastCheck = time.Now()
+		m.mu.Unlock()
+	}
+}
+
+// refreshTools 重新获取服务器的工具列表，之后获取的快照中包含新的工具
+// 获取失败时保留原来的工具列表，连接是否可用由健康检查判断
+func (m *Manager) refreshTools(ctx context.Context, s *managedServer) {
+	m.mu.Lock()
+	mcpClient := s.client.client
+	m.mu.Unlock()
+
+	listCtx, cancel := context.WithTimeout(ctx, initializeTimeout)
+	toolsResult, err := mcpClient.ListTools(listCtx, mcp.ListToolsRequest{})
+	cancel()
+	if err != nil {
+		log.Warn("重新获取工具列表失败",
+			"server", s.config.Name,
+			"error", err)
+		return
+	}
+
+	m.mu.Lock()
+	s.tools = toolsResult.Tools
+	s.status.ToolCount = len(toolsResult.Tools)
+	m.mu.Unlock()
+
+	log.Info("工具列表已更新",
+		"server", s.config.Name,
+		"tools", len(toolsResult.Tools))
+}
+
+// disconnect 淘汰当前连接并更新状态，服务器的工具随之不再提供给模型
+// 没有请求引用的客户端立即关闭，否则在最后一个请求结束后关闭
+func (m *Manager) disconnect(s *managedServer, cause error) {
+	m.mu.Lock()
+	l := s.client
+	closeNow := false
+	if l != nil {
+		// 保留子进程退出前的 stderr 输出，方便排查
+		s.status.Stderr = l.client.Stderr()
+		l.retired = true
+		closeNow = l.refs == 0
+	}
+	s.client = nil
+	s.status.State = StateUnhealthy
+	s.status.Error = cause.Error()
+	s.status.LastCheck = time.Now()
+	m.mu.Unlock()
+
+	if closeNow {
+		l.client.Close()
+	}
+}
+
+// setError 记录连接失败的错误
+func (m *Manager) setError(s *managedServer, err error) {
+	m.mu.Lock()
+	s.status.State = StateUnhealthy
+	s.status.Error = err.Error()
+	s.status.LastCheck = time.Now()
+	m.mu.Unlock()
+}
+
+// sleep 等待 d，ctx 结束时返回 false
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
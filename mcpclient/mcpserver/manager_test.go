@@ -0,0 +1,299 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"mcpclient/models"
+)
+
+// testServer 进程内的 MCP 服务器，通过 Streamable HTTP 提供服务
+type testServer struct {
+	*server.MCPServer
+	URL      string
+	sessions *server.InsecureStatefulSessionIdManager // 调用 Terminate 模拟服务器终止会话
+
+	down   atomic.Bool // 为 true 时所有请求返回 503，模拟服务器不可用
+	mu     sync.Mutex
+	closed []string // 客户端关闭（DELETE）的会话 ID
+}
+
+func newTestServer(t *testing.T, opts ...server.ServerOption) *testServer {
+	ts := &testServer{
+		MCPServer: server.NewMCPServer("test", "1.0.0", opts...),
+		sessions:  &server.InsecureStatefulSessionIdManager{},
+	}
+	handler := server.NewStreamableHTTPServer(ts.MCPServer, server.WithSessionIdManager(ts.sessions))
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ts.down.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.Method {
+		case http.MethodDelete:
+			ts.mu.Lock()
+			ts.closed = append(ts.closed, r.Header.Get(server.HeaderKeySessionID))
+			ts.mu.Unlock()
+		case http.MethodPost:
+			// mcp-go 的服务端没有实现 resources/subscribe，这里直接返回成功
+			body, _ := io.ReadAll(r.Body)
+			var request mcp.JSONRPCRequest
+			if json.Unmarshal(body, &request) == nil && request.Method == "resources/subscribe" {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(mcp.NewJSONRPCResultResponse(request.ID, mcp.EmptyResult{}))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(func() {
+		// 通知长连接不会自己结束，先断开连接再关闭服务器
+		httpServer.CloseClientConnections()
+		httpServer.Close()
+	})
+	ts.URL = httpServer.URL + "/mcp"
+	return ts
+}
+
+// addTool 添加一个返回自身名称的工具
+func (ts *testServer) addTool(name string) {
+	ts.AddTool(mcp.NewTool(name), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(name), nil
+	})
+}
+
+// closedSession 判断客户端是否已经关闭了会话
+func (ts *testServer) closedSession(sessionID string) bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return slices.Contains(ts.closed, sessionID)
+}
+
+func httpConfig(name, url string) models.MCPServerConfig {
+	return models.MCPServerConfig{Name: name, Transport: models.TransportStreamableHTTP, MCPServerURL: url}
+}
+
+// startManager 启动管理器，测试结束时关闭
+func startManager(t *testing.T, opts ManagerOptions, configs ...models.MCPServerConfig) *Manager {
+	m := NewManager(configs, opts)
+	m.Start(context.Background())
+	t.Cleanup(m.Close)
+	return m
+}
+
+// serverStatus 返回指定服务器的状态
+func serverStatus(m *Manager, name string) ServerStatus {
+	for _, status := range m.Status() {
+		if status.Name == name {
+			return status
+		}
+	}
+	return ServerStatus{}
+}
+
+// waitFor 轮询直到 cond 返回 true，超时后测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+// callTool 调用工具并返回文本结果
+func callTool(c Client, name string) (string, error) {
+	request := mcp.CallToolRequest{}
+	request.Params.Name = name
+	result, err := c.CallTool(context.Background(), request)
+	if err != nil {
+		return "", err
+	}
+	if len(result.Content) != 1 {
+		return "", fmt.Errorf("got %d contents", len(result.Content))
+	}
+	text, _ := mcp.AsTextContent(result.Content[0])
+	if text == nil {
+		return "", fmt.Errorf("got %T", result.Content[0])
+	}
+	return text.Text, nil
+}
+
+func TestManagerReconnect(t *testing.T) {
+	ts := newTestServer(t)
+	ts.addTool("hello")
+	m := startManager(t, ManagerOptions{
+		HealthInterval: 20 * time.Millisecond,
+		PingTimeout:    time.Second,
+		MinBackoff:     10 * time.Millisecond,
+		MaxBackoff:     40 * time.Millisecond,
+	}, httpConfig("Demo", ts.URL))
+	if status := serverStatus(m, "Demo"); status.State != StateHealthy {
+		t.Fatalf("state = %q (%s), want healthy", status.State, status.Error)
+	}
+
+	ts.down.Store(true)
+	waitFor(t, "health check failure", func() bool {
+		return serverStatus(m, "Demo").State == StateUnhealthy
+	})
+	snapshot := m.Acquire()
+	if len(snapshot.Clients) != 0 {
+		t.Errorf("unhealthy server is still in the snapshot")
+	}
+	snapshot.Release()
+
+	ts.down.Store(false)
+	waitFor(t, "reconnect", func() bool {
+		return serverStatus(m, "Demo").State == StateHealthy
+	})
+	if status := serverStatus(m, "Demo"); status.Reconnects < 1 || status.Error != "" {
+		t.Errorf("status = %+v, want reconnects >= 1 and no error", status)
+	}
+
+	snapshot = m.Acquire()
+	defer snapshot.Release()
+	if text, err := callTool(snapshot.Clients["Demo"], "hello"); err != nil || text != "hello" {
+		t.Errorf("CallTool() after reconnect = %q, %v", text, err)
+	}
+}
+
+func TestManagerReloadDrainsLease(t *testing.T) {
+	ts := newTestServer(t)
+	ts.addTool("hello")
+	config := httpConfig("Demo", ts.URL)
+	m := startManager(t, ManagerOptions{}, config)
+
+	old := m.Acquire()
+	oldClient := old.Clients["Demo"]
+	if oldClient == nil {
+		t.Fatalf("no client in the snapshot: %+v", serverStatus(m, "Demo"))
+	}
+	oldSession := oldClient.SessionID()
+
+	// 配置没有变化时保持原来的连接
+	if err := m.Reload(context.Background(), []models.MCPServerConfig{httpConfig("Demo", ts.URL)}); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	snapshot := m.Acquire()
+	if snapshot.Clients["Demo"] != oldClient {
+		t.Errorf("unchanged config reconnected the server")
+	}
+	snapshot.Release()
+
+	// 配置变化后新的快照使用新的连接，旧的快照在释放前仍然可用
+	config.Headers = map[string]string{"X-Test": "1"}
+	if err := m.Reload(context.Background(), []models.MCPServerConfig{config}); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	snapshot = m.Acquire()
+	defer snapshot.Release()
+	if c := snapshot.Clients["Demo"]; c == nil || c == oldClient {
+		t.Fatalf("changed config did not reconnect the server")
+	}
+	if ts.closedSession(oldSession) {
+		t.Fatalf("old client closed while a snapshot still uses it")
+	}
+	if text, err := callTool(oldClient, "hello"); err != nil || text != "hello" {
+		t.Errorf("CallTool() on the old snapshot = %q, %v", text, err)
+	}
+
+	old.Release()
+	if !ts.closedSession(oldSession) {
+		t.Errorf("old client not closed after the last snapshot was released")
+	}
+}
+
+func TestManagerToolsListChanged(t *testing.T) {
+	ts := newTestServer(t, server.WithToolCapabilities(true))
+	ts.addTool("hello")
+	m := startManager(t, ManagerOptions{}, httpConfig("Demo", ts.URL))
+
+	ts.addTool("world")
+	waitFor(t, "tool list refresh", func() bool {
+		// 通知长连接可能还没有建立，重复发送直到客户端收到
+		ts.SendNotificationToAllClients(mcp.MethodNotificationToolsListChanged, nil)
+		snapshot := m.Acquire()
+		defer snapshot.Release()
+		return len(snapshot.Tools["Demo"]) == 2
+	})
+	if status := serverStatus(m, "Demo"); status.ToolCount != 2 {
+		t.Errorf("ToolCount = %d, want 2", status.ToolCount)
+	}
+}
+
+func TestManagerResourceCache(t *testing.T) {
+	const uri = "test://counter"
+	ts := newTestServer(t, server.WithResourceCapabilities(true, false))
+	var reads atomic.Int32
+	ts.AddResource(mcp.NewResource(uri, "counter"), func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		return []mcp.ResourceContents{mcp.TextResourceContents{URI: uri, Text: fmt.Sprint(reads.Add(1))}}, nil
+	})
+	m := startManager(t, ManagerOptions{}, httpConfig("Demo", ts.URL))
+
+	read := func() string {
+		t.Helper()
+		contents, err := m.ReadResource(context.Background(), "Demo", uri)
+		if err != nil {
+			t.Fatalf("ReadResource() error = %v", err)
+		}
+		text, ok := contents[0].(mcp.TextResourceContents)
+		if !ok {
+			t.Fatalf("ReadResource() = %T", contents[0])
+		}
+		return text.Text
+	}
+
+	// 订阅成功后第二次读取使用缓存
+	if got := read(); got != "1" {
+		t.Fatalf("first read = %q, want 1", got)
+	}
+	if got := read(); got != "1" || reads.Load() != 1 {
+		t.Fatalf("second read = %q after %d reads, want cached 1", got, reads.Load())
+	}
+
+	waitFor(t, "cache invalidation", func() bool {
+		ts.SendNotificationToAllClients(mcp.MethodNotificationResourceUpdated, map[string]any{"uri": uri})
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		_, cached := m.servers[0].contents[uri]
+		return !cached
+	})
+	if got := read(); got != "2" {
+		t.Errorf("read after update = %q, want 2", got)
+	}
+}
+
+func TestStreamableHTTPSessionRenewal(t *testing.T) {
+	ts := newTestServer(t)
+	ts.addTool("hello")
+	c, err := Connect(context.Background(), httpConfig("Demo", ts.URL))
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer c.Close()
+
+	oldSession := c.SessionID()
+	if _, err := ts.sessions.Terminate(oldSession); err != nil {
+		t.Fatalf("Terminate() error = %v", err)
+	}
+	if text, err := callTool(c, "hello"); err != nil || text != "hello" {
+		t.Fatalf("CallTool() after the session was terminated = %q, %v", text, err)
+	}
+	if session := c.SessionID(); session == "" || session == oldSession {
+		t.Errorf("SessionID() = %q, want a new session", session)
+	}
+}
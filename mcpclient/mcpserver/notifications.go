@@ -0,0 +1,154 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/charmbracelet/log"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// mcp-go 没有定义这两个通知的方法名
+const (
+	methodNotificationProgress = "notifications/progress"
+	methodNotificationMessage  = "notifications/message"
+)
+
+// Progress 服务器在工具调用过程中发送的进度通知（notifications/progress）
+type Progress struct {
+	Progress float64
+	Total    float64 // 为 0 表示总量未知
+	Message  string
+}
+
+// LogMessage 服务器发送的日志通知（notifications/message）
+type LogMessage struct {
+	Server string
+	Level  mcp.LoggingLevel
+	Logger string
+	Data   any
+}
+
+// Observer 接收一次工具调用期间服务器发送的进度和日志通知
+// 回调在传输层读取消息的 goroutine 中执行，不能阻塞
+type Observer struct {
+	OnProgress func(Progress)
+	OnLog      func(LogMessage)
+	MinLevel   mcp.LoggingLevel // 只接收不低于这个级别的日志，为空时使用服务器配置的级别
+	Owner      string           // 发起工具调用的用户 ID，调用期间服务器发起的采样请求由这个用户审批
+}
+
+// 进度 token 的序号，保证同一进程中的 token 不重复
+var progressTokens atomic.Uint64
+
+// observers 一个客户端上正在进行的工具调用的观察者，key 为进度 token
+type observers struct {
+	mu      sync.Mutex
+	byToken map[string]*Observer
+}
+
+// Observe 注册观察者，返回请求中需要携带的进度 token 和取消注册的函数
+// 服务器的日志不属于某个请求，只有这个客户端上恰好有一个观察者时才会发送给它
+func (c *serverClient) Observe(observer Observer) (mcp.ProgressToken, func()) {
+	token := fmt.Sprintf("%s-%d", c.config.Name, progressTokens.Add(1))
+	c.observers.mu.Lock()
+	if c.observers.byToken == nil {
+		c.observers.byToken = make(map[string]*Observer)
+	}
+	c.observers.byToken[token] = &observer
+	c.observers.mu.Unlock()
+
+	return token, func() {
+		c.observers.mu.Lock()
+		delete(c.observers.byToken, token)
+		c.observers.mu.Unlock()
+	}
+}
+
+// activeOwner 返回正在这个客户端上执行工具调用的用户，没有进行中的调用或者调用属于多个用户时返回空字符串
+func (c *serverClient) activeOwner() string {
+	c.observers.mu.Lock()
+	defer c.observers.mu.Unlock()
+	owner := ""
+	for _, observer := range c.observers.byToken {
+		if observer.Owner == "" || (owner != "" && observer.Owner != owner) {
+			return ""
+		}
+		owner = observer.Owner
+	}
+	return owner
+}
+
+// dispatchNotification 把进度通知发送给对应 token 的观察者
+// 日志通知无法确定属于哪个请求，客户端由所有用户共享，同时有多个观察者时转发会把一个用户的数据
+// 泄露到其他用户的对话中，因此只在恰好有一个观察者时转发，否则只记录在本地日志中
+func (c *serverClient) dispatchNotification(notification mcp.JSONRPCNotification) {
+	fields := notification.Params.AdditionalFields
+	switch notification.Method {
+	case methodNotificationProgress:
+		progress := Progress{}
+		progress.Progress, _ = fields["progress"].(float64)
+		progress.Total, _ = fields["total"].(float64)
+		progress.Message, _ = fields["message"].(string)
+		token := fmt.Sprint(fields["progressToken"])
+
+		c.observers.mu.Lock()
+		observer := c.observers.byToken[token]
+		c.observers.mu.Unlock()
+		if observer != nil && observer.OnProgress != nil {
+			observer.OnProgress(progress)
+		}
+	case methodNotificationMessage:
+		message := LogMessage{Server: c.config.Name}
+		level, _ := fields["level"].(string)
+		message.Level = mcp.LoggingLevel(level)
+		message.Logger, _ = fields["logger"].(string)
+		message.Data = fields["data"]
+		// 服务器不一定遵守 logging/setLevel，这里再按配置的级别过滤一次
+		if !message.Level.ShouldSendTo(mcp.LoggingLevel(c.config.GetLogLevel())) {
+			return
+		}
+		log.Debug("MCP 服务器日志",
+			"server", c.config.Name,
+			"level", message.Level,
+			"logger", message.Logger,
+			"data", message.Data)
+
+		c.observers.mu.Lock()
+		var observer *Observer
+		if len(c.observers.byToken) == 1 {
+			for _, o := range c.observers.byToken {
+				observer = o
+			}
+		}
+		c.observers.mu.Unlock()
+		if observer == nil || observer.OnLog == nil {
+			return
+		}
+		if observer.MinLevel != "" && !message.Level.ShouldSendTo(observer.MinLevel) {
+			return
+		}
+		observer.OnLog(message)
+	}
+}
+
+// ValidLogLevel 判断是否为 MCP 协议定义的日志级别
+func ValidLogLevel(level string) bool {
+	// 未知的级别与任何级别比较都返回 false
+	return mcp.LoggingLevel(level).ShouldSendTo(mcp.LoggingLevelDebug)
+}
+
+type logLevelsKey struct{}
+
+// WithLogLevels 返回携带用户设置的各服务器最低日志级别的 ctx，key 为服务器名称
+func WithLogLevels(ctx context.Context, levels map[string]string) context.Context {
+	return context.WithValue(ctx, logLevelsKey{}, levels)
+}
+
+// LogLevelFromContext 返回用户为服务器设置的最低日志级别，没有设置时返回空字符串
+func LogLevelFromContext(ctx context.Context, serverName string) mcp.LoggingLevel {
+	levels, _ := ctx.Value(logLevelsKey{}).(map[string]string)
+	return mcp.LoggingLevel(levels[serverName])
+}
@@ -0,0 +1,58 @@
+package mcpserver
+
+import (
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"mcpclient/models"
+)
+
+func logNotification(level string, data any) mcp.JSONRPCNotification {
+	notification := mcp.JSONRPCNotification{}
+	notification.Method = methodNotificationMessage
+	notification.Params.AdditionalFields = map[string]any{"level": level, "data": data}
+	return notification
+}
+
+func TestDispatchLogNotification(t *testing.T) {
+	c := &serverClient{config: models.MCPServerConfig{Name: "Demo"}}
+
+	var alice, bob []any
+	_, stopAlice := c.Observe(Observer{OnLog: func(m LogMessage) { alice = append(alice, m.Data) }})
+
+	// 只有一个观察者时转发给它，低于服务器配置级别（默认 info）的日志不转发
+	c.dispatchNotification(logNotification("info", "alice-1"))
+	c.dispatchNotification(logNotification("debug", "alice-debug"))
+
+	// 有多个观察者时无法判断日志属于谁，不转发给任何一个
+	_, stopBob := c.Observe(Observer{OnLog: func(m LogMessage) { bob = append(bob, m.Data) }})
+	c.dispatchNotification(logNotification("error", "shared"))
+
+	stopAlice()
+	c.dispatchNotification(logNotification("info", "bob-1"))
+	stopBob()
+	c.dispatchNotification(logNotification("info", "nobody"))
+
+	if len(alice) != 1 || alice[0] != "alice-1" {
+		t.Errorf("alice received %v", alice)
+	}
+	if len(bob) != 1 || bob[0] != "bob-1" {
+		t.Errorf("bob received %v", bob)
+	}
+}
+
+func TestDispatchLogNotificationMinLevel(t *testing.T) {
+	c := &serverClient{config: models.MCPServerConfig{Name: "Demo"}}
+	var received []any
+	_, stop := c.Observe(Observer{
+		OnLog:    func(m LogMessage) { received = append(received, m.Data) },
+		MinLevel: mcp.LoggingLevelWarning,
+	})
+	defer stop()
+
+	c.dispatchNotification(logNotification("info", "info"))
+	c.dispatchNotification(logNotification("error", "error"))
+	if len(received) != 1 || received[0] != "error" {
+		t.Errorf("received %v", received)
+	}
+}
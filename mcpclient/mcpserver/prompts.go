@@ -0,0 +1,247 @@
+package mcpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/log"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var (
+	ErrPromptNotFound         = errors.New("提示模板不存在")
+	ErrInvalidPromptArguments = errors.New("提示模板参数错误")
+)
+
+// PromptInfo 一个服务器上的提示模板，以及在对话中使用它的斜杠命令
+type PromptInfo struct {
+	Server      string               `json:"server"`
+	Command     string               `json:"command"` // 例如 /Demo.summarize
+	Name        string               `json:"name"`
+	Description string               `json:"description,omitempty"`
+	Arguments   []mcp.PromptArgument `json:"arguments"`
+}
+
+// PromptCommand 从用户消息中解析出的斜杠命令，格式为 /服务器名称.提示模板名称 参数名=参数值 ...
+// 参数值中有空格时可以用双引号括起来，例如 /Demo.summarize topic="MCP 协议"；
+// 参数值中的双引号和反斜杠用反斜杠转义，例如 text="他说 \"你好\""
+type PromptCommand struct {
+	Server    string
+	Prompt    string
+	Arguments map[string]string
+}
+
+// Prompts 按配置顺序返回所有 healthy 服务器的提示模板
+func (m *Manager) Prompts() []PromptInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prompts := []PromptInfo{}
+	for _, s := range m.servers {
+		if s.status.State != StateHealthy {
+			continue
+		}
+		for _, prompt := range s.prompts {
+			arguments := prompt.Arguments
+			if arguments == nil {
+				arguments = []mcp.PromptArgument{}
+			}
+			prompts = append(prompts, PromptInfo{
+				Server:      s.config.Name,
+				Command:     "/" + s.config.Name + "." + prompt.Name,
+				Name:        prompt.Name,
+				Description: prompt.Description,
+				Arguments:   arguments,
+			})
+		}
+	}
+	return prompts
+}
+
+// ParseCommand 解析用户消息中的斜杠命令
+// 消息不是斜杠命令，或者命令中的服务器名称不在配置中时返回 nil，消息按普通文本处理；
+// 是斜杠命令但参数格式错误时返回错误
+func (m *Manager) ParseCommand(text string) (*PromptCommand, error) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return nil, nil
+	}
+	name, rest := text[1:], ""
+	if i := strings.IndexFunc(name, unicode.IsSpace); i >= 0 {
+		name, rest = name[:i], name[i:]
+	}
+
+	// 服务器名称中可能包含 "."，按最长的服务器名称匹配
+	m.mu.Lock()
+	var server string
+	for _, s := range m.servers {
+		if strings.HasPrefix(name, s.config.Name+".") && len(s.config.Name) > len(server) {
+			server = s.config.Name
+		}
+	}
+	m.mu.Unlock()
+	if server == "" || len(name) == len(server)+1 {
+		return nil, nil
+	}
+
+	tokens, err := splitArguments(rest)
+	if err != nil {
+		return nil, err
+	}
+	arguments := make(map[string]string, len(tokens))
+	for _, token := range tokens {
+		key, value, ok := strings.Cut(token, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%w: %s 的格式应为 参数名=参数值", ErrInvalidPromptArguments, token)
+		}
+		if _, ok := arguments[key]; ok {
+			return nil, fmt.Errorf("%w: 参数 %s 重复", ErrInvalidPromptArguments, key)
+		}
+		arguments[key] = value
+	}
+	return &PromptCommand{
+		Server:    server,
+		Prompt:    name[len(server)+1:],
+		Arguments: arguments,
+	}, nil
+}
+
+// GetPrompt 按提示模板声明的参数校验参数后，从服务器获取展开后的提示消息
+func (m *Manager) GetPrompt(ctx context.Context, serverName, promptName string, arguments map[string]string) (*mcp.GetPromptResult, error) {
+	m.mu.Lock()
+	s, err := m.healthyServer(serverName)
+	if err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	var prompt *mcp.Prompt
+	for i := range s.prompts {
+		if s.prompts[i].Name == promptName {
+			prompt = &s.prompts[i]
+			break
+		}
+	}
+	if prompt == nil {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s.%s", ErrPromptNotFound, serverName, promptName)
+	}
+	if err := validatePromptArguments(prompt, arguments); err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	l := s.client
+	l.refs++
+	m.mu.Unlock()
+	defer m.release(l)
+
+	request := mcp.GetPromptRequest{}
+	request.Params.Name = promptName
+	request.Params.Arguments = arguments
+	return l.client.GetPrompt(ctx, request)
+}
+
+// validatePromptArguments 检查参数是否都在提示模板中声明，并且提供了所有必填参数
+func validatePromptArguments(prompt *mcp.Prompt, arguments map[string]string) error {
+	declared := make(map[string]bool, len(prompt.Arguments))
+	var missing []string
+	for _, argument := range prompt.Arguments {
+		declared[argument.Name] = true
+		if _, ok := arguments[argument.Name]; argument.Required && !ok {
+			missing = append(missing, argument.Name)
+		}
+	}
+	var unknown []string
+	for name := range arguments {
+		if !declared[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: 提示模板 %s 没有参数 %s", ErrInvalidPromptArguments, prompt.Name, strings.Join(unknown, ", "))
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: 提示模板 %s 缺少必填参数 %s", ErrInvalidPromptArguments, prompt.Name, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// splitArguments 按空白分割参数，双引号中的空白不分割，引号本身会被去掉；
+// 反斜杠后面的字符按原样保留，用于在参数值中写入双引号和反斜杠
+func splitArguments(s string) ([]string, error) {
+	var tokens []string
+	var current strings.Builder
+	inQuotes, hasToken, escaped := false, false, false
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+			hasToken = true
+		case r == '"':
+			inQuotes = !inQuotes
+			hasToken = true
+		case !inQuotes && unicode.IsSpace(r):
+			if hasToken {
+				tokens = append(tokens, current.String())
+				current.Reset()
+				hasToken = false
+			}
+		default:
+			current.WriteRune(r)
+			hasToken = true
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("%w: 反斜杠后面缺少要转义的字符", ErrInvalidPromptArguments)
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("%w: 引号没有闭合", ErrInvalidPromptArguments)
+	}
+	if hasToken {
+		tokens = append(tokens, current.String())
+	}
+	return tokens, nil
+}
+
+// refreshPrompts 收到 prompts/list_changed 通知后重新获取提示模板列表
+func (m *Manager) refreshPrompts(ctx context.Context, s *managedServer) {
+	m.mu.Lock()
+	mcpClient := s.client.client
+	m.mu.Unlock()
+
+	prompts := listPrompts(ctx, s.config.Name, mcpClient)
+
+	m.mu.Lock()
+	s.prompts = prompts
+	s.status.PromptCount = len(prompts)
+	m.mu.Unlock()
+
+	log.Info("提示模板列表已更新",
+		"server", s.config.Name,
+		"prompts", len(prompts))
+}
+
+// listPrompts 获取服务器的提示模板列表，服务器不支持提示模板或者获取失败时返回空列表
+func listPrompts(ctx context.Context, serverName string, mcpClient Client) []mcp.Prompt {
+	if mcpClient.GetServerCapabilities().Prompts == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, initializeTimeout)
+	defer cancel()
+
+	result, err := mcpClient.ListPrompts(ctx, mcp.ListPromptsRequest{})
+	if err != nil {
+		log.Warn("获取提示模板列表失败", "server", serverName, "error", err)
+		return nil
+	}
+	return result.Prompts
+}
@@ -0,0 +1,162 @@
+package mcpserver
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"mcpclient/models"
+)
+
+func TestSplitArguments(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		tokens []string
+		err    string // 期望的错误，为空表示成功
+	}{
+		{name: "empty", input: "  ", tokens: nil},
+		{name: "plain", input: " a=1  b=2 ", tokens: []string{"a=1", "b=2"}},
+		{name: "quoted value", input: `topic="MCP 协议" n=3`, tokens: []string{"topic=MCP 协议", "n=3"}},
+		{name: "empty quoted value", input: `topic=""`, tokens: []string{"topic="}},
+		{name: "escaped quote", input: `text="他说 \"你好\""`, tokens: []string{`text=他说 "你好"`}},
+		{name: "escaped backslash", input: `path=C:\\dir`, tokens: []string{`path=C:\dir`}},
+		{name: "escaped space outside quotes", input: `a=x\ y`, tokens: []string{"a=x y"}},
+		{name: "unclosed quote", input: `topic="MCP`, err: "引号没有闭合"},
+		{name: "trailing backslash", input: `a=x\`, err: "反斜杠"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := splitArguments(tt.input)
+			if tt.err != "" {
+				if !errors.Is(err, ErrInvalidPromptArguments) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("splitArguments() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(tokens, tt.tokens) {
+				t.Errorf("splitArguments() = %q, %v, want %q", tokens, err, tt.tokens)
+			}
+		})
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	m := NewManager([]models.MCPServerConfig{{Name: "Demo"}, {Name: "Demo.v2"}}, ManagerOptions{})
+	tests := []struct {
+		name    string
+		text    string
+		command *PromptCommand // 为 nil 时期望按普通文本处理
+		err     string
+	}{
+		{name: "not a command", text: "你好 /Demo.summarize"},
+		{name: "unknown server", text: "/Other.summarize topic=mcp"},
+		{name: "server without prompt", text: "/Demo. topic=mcp"},
+		{name: "server only", text: "/Demo"},
+		{
+			name:    "no arguments",
+			text:    "  /Demo.summarize  ",
+			command: &PromptCommand{Server: "Demo", Prompt: "summarize", Arguments: map[string]string{}},
+		},
+		{
+			name:    "longest server name wins",
+			text:    `/Demo.v2.summarize topic="MCP 协议"`,
+			command: &PromptCommand{Server: "Demo.v2", Prompt: "summarize", Arguments: map[string]string{"topic": "MCP 协议"}},
+		},
+		{
+			name:    "prompt name with a dot",
+			text:    "/Demo.v3.summarize",
+			command: &PromptCommand{Server: "Demo", Prompt: "v3.summarize", Arguments: map[string]string{}},
+		},
+		{
+			name:    "value with equals sign",
+			text:    "/Demo.query filter=a=b",
+			command: &PromptCommand{Server: "Demo", Prompt: "query", Arguments: map[string]string{"filter": "a=b"}},
+		},
+		{name: "missing equals sign", text: "/Demo.summarize topic", err: "参数名=参数值"},
+		{name: "empty key", text: "/Demo.summarize =mcp", err: "参数名=参数值"},
+		{name: "duplicate argument", text: "/Demo.summarize topic=a topic=b", err: "参数 topic 重复"},
+		{name: "unclosed quote", text: `/Demo.summarize topic="a`, err: "引号没有闭合"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command, err := m.ParseCommand(tt.text)
+			if tt.err != "" {
+				if !errors.Is(err, ErrInvalidPromptArguments) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("ParseCommand() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(command, tt.command) {
+				t.Errorf("ParseCommand() = %+v, %v, want %+v", command, err, tt.command)
+			}
+		})
+	}
+}
+
+func TestValidatePromptArguments(t *testing.T) {
+	prompt := &mcp.Prompt{
+		Name: "summarize",
+		Arguments: []mcp.PromptArgument{
+			{Name: "topic", Required: true},
+			{Name: "style", Required: true},
+			{Name: "length"},
+		},
+	}
+	tests := []struct {
+		name      string
+		arguments map[string]string
+		err       string // 期望的错误，为空表示成功
+	}{
+		{name: "required only", arguments: map[string]string{"topic": "mcp", "style": "brief"}},
+		{name: "optional", arguments: map[string]string{"topic": "mcp", "style": "brief", "length": "short"}},
+		{name: "empty value counts as present", arguments: map[string]string{"topic": "", "style": ""}},
+		{name: "missing required", arguments: map[string]string{"length": "short"}, err: "缺少必填参数 topic, style"},
+		{name: "unknown arguments", arguments: map[string]string{"topic": "mcp", "style": "brief", "b": "1", "a": "2"}, err: "没有参数 a, b"},
+		{name: "unknown reported before missing", arguments: map[string]string{"tpoic": "mcp"}, err: "没有参数 tpoic"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePromptArguments(prompt, tt.arguments)
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("validatePromptArguments() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidPromptArguments) || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("validatePromptArguments() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestGetPromptErrors(t *testing.T) {
+	m := NewManager([]models.MCPServerConfig{{Name: "Demo"}, {Name: "Down"}}, ManagerOptions{})
+	m.servers[0].status.State = StateHealthy
+	m.servers[0].client = &lease{}
+	m.servers[0].prompts = []mcp.Prompt{{Name: "summarize"}}
+
+	tests := []struct {
+		name      string
+		server    string
+		prompt    string
+		arguments map[string]string
+		err       error
+	}{
+		{name: "unknown prompt", server: "Demo", prompt: "translate", err: ErrPromptNotFound},
+		{name: "unknown server", server: "Other", prompt: "summarize", err: ErrServerNotFound},
+		{name: "server not healthy", server: "Down", prompt: "summarize", err: ErrServerUnavailable},
+		{name: "undeclared argument", server: "Demo", prompt: "summarize", arguments: map[string]string{"topic": "mcp"}, err: ErrInvalidPromptArguments},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.GetPrompt(context.Background(), tt.server, tt.prompt, tt.arguments); !errors.Is(err, tt.err) {
+				t.Errorf("GetPrompt() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package mcpserver
+
+import (
+	"context"
+	"errors"
+
+	"github.com/charmbracelet/log"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var (
+	ErrServerNotFound    = errors.New("MCP 服务器不存在")
+	ErrServerUnavailable = errors.New("MCP 服务器当前不可用")
+)
+
+// Resources 返回服务器最近一次获取到的资源列表和资源模板列表
+func (m *Manager) Resources(serverName string) ([]mcp.Resource, []mcp.ResourceTemplate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.healthyServer(serverName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.resources, s.templates, nil
+}
+
+// ReadResource 读取服务器上的资源，优先使用缓存
+// 服务器支持订阅时，第一次读取后订阅资源的更新，收到 resources/updated 通知后缓存失效，下次读取时重新获取
+func (m *Manager) ReadResource(ctx context.Context, serverName, uri string) ([]mcp.ResourceContents, error) {
+	m.mu.Lock()
+	s, err := m.healthyServer(serverName)
+	if err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	if contents, ok := s.contents[uri]; ok {
+		m.mu.Unlock()
+		return contents, nil
+	}
+	// 读取期间持有客户端的引用，避免被重新加载配置关闭
+	l := s.client
+	l.refs++
+	version := s.contentVersion
+	subscribed := s.subscribed[uri]
+	subscribeFailed := s.subscribeFailed
+	m.mu.Unlock()
+	defer m.release(l)
+
+	request := mcp.ReadResourceRequest{}
+	request.Params.URI = uri
+	result, err := l.client.ReadResource(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	capabilities := l.client.GetServerCapabilities()
+	if !subscribed && !subscribeFailed && capabilities.Resources != nil && capabilities.Resources.Subscribe {
+		subscribeRequest := mcp.SubscribeRequest{}
+		subscribeRequest.Params.URI = uri
+		if err := l.client.Subscribe(ctx, subscribeRequest); err != nil {
+			// 有的服务器声明了订阅能力但没有实现，订阅失败后这个连接不再尝试订阅，资源每次都重新读取
+			log.Warn("订阅资源更新失败，资源将不会被缓存", "server", serverName, "uri", uri, "error", err)
+			m.mu.Lock()
+			if s.client == l {
+				s.subscribeFailed = true
+			}
+			m.mu.Unlock()
+			return result.Contents, nil
+		}
+		subscribed = true
+	}
+
+	m.mu.Lock()
+	// 只缓存已订阅的资源，并且连接没有变化、读取期间资源没有更新
+	if s.client == l && subscribed {
+		s.subscribed[uri] = true
+		if s.contentVersion == version {
+			s.contents[uri] = result.Contents
+		}
+	}
+	m.mu.Unlock()
+	return result.Contents, nil
+}
+
+// healthyServer 按名称查找 healthy 状态的服务器，调用时需要持有 m.mu
+func (m *Manager) healthyServer(serverName string) (*managedServer, error) {
+	for _, s := range m.servers {
+		if s.config.Name != serverName {
+			continue
+		}
+		if s.status.State != StateHealthy {
+			return nil, ErrServerUnavailable
+		}
+		return s, nil
+	}
+	return nil, ErrServerNotFound
+}
+
+// invalidateResource 收到 resources/updated 通知后删除资源的缓存
+func (m *Manager) invalidateResource(s *managedServer, uri string) {
+	m.mu.Lock()
+	delete(s.contents, uri)
+	s.contentVersion++
+	m.mu.Unlock()
+	log.Debug("资源已更新", "server", s.config.Name, "uri", uri)
+}
+
+// refreshResources 收到 resources/list_changed 通知后重新获取资源列表
+func (m *Manager) refreshResources(ctx context.Context, s *managedServer) {
+	m.mu.Lock()
+	mcpClient := s.client.client
+	m.mu.Unlock()
+
+	resources, templates := listResources(ctx, s.config.Name, mcpClient)
+
+	m.mu.Lock()
+	s.resources = resources
+	s.templates = templates
+	s.status.ResourceCount = len(resources)
+	m.mu.Unlock()
+
+	log.Info("资源列表已更新",
+		"server", s.config.Name,
+		"resources", len(resources))
+}
+
+// listResources 获取服务器的资源和资源模板列表，服务器不支持资源或者获取失败时返回空列表
+func listResources(ctx context.Context, serverName string, mcpClient Client) ([]mcp.Resource, []mcp.ResourceTemplate) {
+	if mcpClient.GetServerCapabilities().Resources == nil {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, initializeTimeout)
+	defer cancel()
+
+	var resources []mcp.Resource
+	resourcesResult, err := mcpClient.ListResources(ctx, mcp.ListResourcesRequest{})
+	if err != nil {
+		log.Warn("获取资源列表失败", "server", serverName, "error", err)
+	} else {
+		resources = resourcesResult.Resources
+	}
+
+	var templates []mcp.ResourceTemplate
+	templatesResult, err := mcpClient.ListResourceTemplates(ctx, mcp.ListResourceTemplatesRequest{})
+	if err != nil {
+		log.Warn("获取资源模板列表失败", "server", serverName, "error", err)
+	} else {
+		templates = templatesResult.ResourceTemplates
+	}
+	return resources, templates
+}
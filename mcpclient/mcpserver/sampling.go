@@ -0,0 +1,90 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/mark3labs/mcp-go/mcp"
+	"mcpclient/models"
+)
+
+// 一次采样请求（不包括等待审批的时间）的超时时间
+const samplingTimeout = 2 * time.Minute
+
+// Sampler 使用客户端配置的模型执行服务器发起的采样请求（sampling/createMessage）
+type Sampler interface {
+	CreateMessage(ctx context.Context, serverName string, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error)
+}
+
+// samplingHandler 按服务器配置的采样策略处理采样请求，实现 mcp-go 的 client.SamplingHandler
+type samplingHandler struct {
+	config    models.MCPServerConfig
+	sampler   Sampler
+	approvals *Approvals
+	admins    bool                         // 是否配置了管理员
+	client    atomic.Pointer[serverClient] // 连接建立后设置，用于找到发起工具调用的用户
+}
+
+// CreateMessage 处理服务器发起的采样请求
+// allow 直接执行；approve 先审批，同意后执行。服务器的采样请求不会说明属于哪个工具调用，
+// 只有一个用户的工具调用正在这个服务器上执行时由这个用户审批，否则由管理员审批；
+// 这时没有配置管理员，请求直接拒绝，不等待超时
+func (h *samplingHandler) CreateMessage(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	switch h.config.GetSampling() {
+	case models.SamplingAllow:
+	case models.SamplingApprove:
+		owner := ""
+		if c := h.client.Load(); c != nil {
+			owner = c.activeOwner()
+		}
+		if owner == "" && !h.admins {
+			return nil, fmt.Errorf("采样请求没有可以审批的用户: %w", ErrNoApprover)
+		}
+		decision, err := h.approvals.Request(ctx, Approval{
+			Kind:    "sampling",
+			Server:  h.config.Name,
+			Owner:   owner,
+			Summary: samplingSummary(request),
+			Detail:  request.CreateMessageParams,
+		}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("采样请求没有通过审批: %w", err)
+		}
+		if !decision.Approved {
+			if decision.Reason != "" {
+				return nil, fmt.Errorf("采样请求被拒绝: %s", decision.Reason)
+			}
+			return nil, fmt.Errorf("采样请求被拒绝")
+		}
+	default:
+		return nil, fmt.Errorf("MCP 服务器 %s 不允许使用采样", h.config.Name)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, samplingTimeout)
+	defer cancel()
+	result, err := h.sampler.CreateMessage(ctx, h.config.Name, request)
+	if err != nil {
+		log.Warn("采样请求失败", "server", h.config.Name, "error", err)
+		return nil, err
+	}
+	log.Info("采样请求完成", "server", h.config.Name, "model", result.Model)
+	return result, nil
+}
+
+// samplingSummary 生成审批时展示给用户的采样请求说明
+func samplingSummary(request mcp.CreateMessageRequest) string {
+	summary := fmt.Sprintf("请求使用模型生成回复（%d 条消息，最多 %d 个 token）", len(request.Messages), request.MaxTokens)
+	if n := len(request.Messages); n > 0 {
+		if text, ok := mcp.AsTextContent(request.Messages[n-1].Content); ok {
+			content := []rune(text.Text)
+			if len(content) > 100 {
+				content = append(content[:100], []rune("...")...)
+			}
+			summary += "：" + string(content)
+		}
+	}
+	return summary
+}
@@ -0,0 +1,105 @@
+package mcpserver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"mcpclient/models"
+)
+
+// echoSampler 返回固定回复的 Sampler
+type echoSampler struct{}
+
+func (echoSampler) CreateMessage(ctx context.Context, serverName string, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	return &mcp.CreateMessageResult{
+		SamplingMessage: mcp.SamplingMessage{Role: mcp.RoleAssistant, Content: mcp.NewTextContent("ok")},
+		Model:           "mock",
+	}, nil
+}
+
+func newSamplingHandler(admins bool) (*samplingHandler, *serverClient) {
+	config := models.MCPServerConfig{Name: "Demo", Sampling: models.SamplingApprove}
+	h := &samplingHandler{
+		config:    config,
+		sampler:   echoSampler{},
+		approvals: NewApprovals(time.Second),
+		admins:    admins,
+	}
+	c := &serverClient{config: config}
+	h.client.Store(c)
+	return h, c
+}
+
+// waitPending 等待出现一个对 userID 可见的审批请求
+func waitPending(t *testing.T, approvals *Approvals, userID string, admin bool) Approval {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if pending := approvals.Pending(userID, admin); len(pending) == 1 {
+			return pending[0]
+		}
+	}
+	t.Fatal("no pending approval")
+	return Approval{}
+}
+
+func TestSamplingApprovalOwner(t *testing.T) {
+	tests := []struct {
+		name    string
+		owners  []string // 正在这个服务器上调用工具的用户
+		admins  bool
+		owner   string // 期望的审批用户，为空表示管理员
+		wantErr error  // 不等待审批直接返回的错误
+	}{
+		{name: "single user", owners: []string{"42"}, owner: "42"},
+		{name: "same user twice", owners: []string{"42", "42"}, owner: "42"},
+		{name: "several users fall back to admins", owners: []string{"42", "43"}, admins: true},
+		{name: "no tool call falls back to admins", admins: true},
+		{name: "no approver", owners: []string{"42", "43"}, wantErr: ErrNoApprover},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, c := newSamplingHandler(tt.admins)
+			for _, owner := range tt.owners {
+				_, stop := c.Observe(Observer{Owner: owner})
+				defer stop()
+			}
+
+			type response struct {
+				result *mcp.CreateMessageResult
+				err    error
+			}
+			done := make(chan response, 1)
+			go func() {
+				result, err := h.CreateMessage(context.Background(), mcp.CreateMessageRequest{})
+				done <- response{result, err}
+			}()
+
+			if tt.wantErr != nil {
+				if r := <-done; !errors.Is(r.err, tt.wantErr) {
+					t.Fatalf("CreateMessage() error = %v, want %v", r.err, tt.wantErr)
+				}
+				return
+			}
+
+			approval := waitPending(t, h.approvals, tt.owner, tt.owner == "")
+			if approval.Owner != tt.owner {
+				t.Errorf("owner = %q, want %q", approval.Owner, tt.owner)
+			}
+			if tt.owner != "" {
+				// 其他用户看不到这个审批
+				if pending := h.approvals.Pending("other", false); len(pending) != 0 {
+					t.Errorf("other user sees %v", pending)
+				}
+			}
+			if err := h.approvals.Decide(approval.ID, tt.owner, tt.owner == "", Decision{Approved: true}); err != nil {
+				t.Fatalf("Decide() error = %v", err)
+			}
+			if r := <-done; r.err != nil || r.result.Model != "mock" {
+				t.Errorf("CreateMessage() = %+v, %v", r.result, r.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package mcpserver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/log"
+	"mcpclient/models"
+)
+
+// resolveConfig 返回解析了请求头、认证信息和环境变量中 ${env:NAME}、${secret:NAME} 引用的配置副本
+// 密钥文件只在配置引用了密钥时读取，每次连接都重新读取，修改密钥后重新连接即可生效。
+// 返回的配置包含明文密钥，不能写入日志
+func resolveConfig(config models.MCPServerConfig, secretsFile string) (models.MCPServerConfig, error) {
+	var secrets map[string]string
+	resolve := func(value string) (string, error) {
+		var resolveErr error
+		resolved := models.SecretReference.ReplaceAllStringFunc(value, func(reference string) string {
+			match := models.SecretReference.FindStringSubmatch(reference)
+			source, name := match[1], match[2]
+			if source == "env" {
+				v, ok := os.LookupEnv(name)
+				if !ok && resolveErr == nil {
+					resolveErr = fmt.Errorf("MCP 服务器 %s 引用的环境变量 %s 未设置", config.Name, name)
+				}
+				return v
+			}
+			if secrets == nil {
+				loaded, err := loadSecrets(secretsFile)
+				if err != nil {
+					if resolveErr == nil {
+						resolveErr = fmt.Errorf("MCP 服务器 %s 读取密钥文件失败: %w", config.Name, err)
+					}
+					return ""
+				}
+				secrets = loaded
+			}
+			v, ok := secrets[name]
+			if !ok && resolveErr == nil {
+				resolveErr = fmt.Errorf("MCP 服务器 %s 引用的密钥 %s 不存在", config.Name, name)
+			}
+			return v
+		})
+		return resolved, resolveErr
+	}
+	resolveValues := func(values map[string]string) (map[string]string, error) {
+		if values == nil {
+			return nil, nil
+		}
+		resolved := make(map[string]string, len(values))
+		for key, value := range values {
+			v, err := resolve(value)
+			if err != nil {
+				return nil, err
+			}
+			resolved[key] = v
+		}
+		return resolved, nil
+	}
+
+	var err error
+	if config.Headers, err = resolveValues(config.Headers); err != nil {
+		return config, err
+	}
+	if config.Env, err = resolveValues(config.Env); err != nil {
+		return config, err
+	}
+	if config.Auth != nil {
+		auth := *config.Auth
+		if auth.Token, err = resolve(auth.Token); err != nil {
+			return config, err
+		}
+		if auth.Username, err = resolve(auth.Username); err != nil {
+			return config, err
+		}
+		if auth.Password, err = resolve(auth.Password); err != nil {
+			return config, err
+		}
+		config.Auth = &auth
+	}
+	return config, nil
+}
+
+// loadSecrets 读取密钥文件，文件内容为 JSON 对象，key 为密钥名称
+func loadSecrets(secretsFile string) (map[string]string, error) {
+	if secretsFile == "" {
+		return nil, fmt.Errorf("没有配置密钥文件 mcp.secrets_file")
+	}
+	info, err := os.Stat(secretsFile)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		log.Warn("密钥文件可以被其他用户读取，建议将权限设置为 600", "file", secretsFile, "mode", info.Mode().Perm())
+	}
+	data, err := os.ReadFile(secretsFile)
+	if err != nil {
+		return nil, err
+	}
+	var secrets map[string]string
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", secretsFile, err)
+	}
+	return secrets, nil
+}
+
+// httpHeaders 返回连接远程服务器时附加的请求头，认证信息转换为 Authorization 请求头
+// config 需要是 resolveConfig 解析后的配置
+func httpHeaders(config models.MCPServerConfig) map[string]string {
+	headers := make(map[string]string, len(config.Headers)+1)
+	for key, value := range config.Headers {
+		headers[key] = value
+	}
+	if config.Auth != nil {
+		switch config.Auth.Type {
+		case models.AuthBearer:
+			headers["Authorization"] = "Bearer " + config.Auth.Token
+		case models.AuthBasic:
+			credentials := config.Auth.Username + ":" + config.Auth.Password
+			headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+		}
+	}
+	return headers
+}
+
+// validateAuth 检查请求头和认证配置，只有远程服务器才能配置
+func validateAuth(config models.MCPServerConfig) error {
+	if config.GetTransport() == models.TransportStdio {
+		if len(config.Headers) > 0 || config.Auth != nil {
+			return fmt.Errorf("MCP 服务器 %s 的传输方式 stdio 不支持 headers 和 auth，请使用 env", config.Name)
+		}
+		return nil
+	}
+	if config.Auth == nil {
+		return nil
+	}
+	switch config.Auth.Type {
+	case models.AuthBearer:
+		if config.Auth.Token == "" {
+			return fmt.Errorf("MCP 服务器 %s 的 bearer 认证没有配置 token", config.Name)
+		}
+	case models.AuthBasic:
+		if config.Auth.Username == "" {
+			return fmt.Errorf("MCP 服务器 %s 的 basic 认证没有配置 username", config.Name)
+		}
+	default:
+		return fmt.Errorf("MCP 服务器 %s 的认证方式 %s 不受支持", config.Name, config.Auth.Type)
+	}
+	return nil
+}
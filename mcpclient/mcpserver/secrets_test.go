@@ -0,0 +1,106 @@
+package mcpserver
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mcpclient/models"
+)
+
+func TestResolveConfig(t *testing.T) {
+	t.Setenv("MCP_TEST_TOKEN", "env-token")
+	secretsFile := filepath.Join(t.TempDir(), "secrets.json")
+	if err := os.WriteFile(secretsFile, []byte(`{"api_key":"file-key","password":"p@ss"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		config      models.MCPServerConfig
+		secretsFile string
+		want        models.MCPServerConfig // 期望解析后的配置
+		err         string                 // 期望的错误，为空表示成功
+	}{
+		{
+			name:   "no references",
+			config: models.MCPServerConfig{Name: "Demo", Headers: map[string]string{"X-Plain": "value"}},
+			want:   models.MCPServerConfig{Name: "Demo", Headers: map[string]string{"X-Plain": "value"}},
+		},
+		{
+			name: "env and secret references",
+			config: models.MCPServerConfig{
+				Name:    "Demo",
+				Headers: map[string]string{"X-Api-Key": "${secret:api_key}", "X-Mixed": "key=${env:MCP_TEST_TOKEN};v=1"},
+				Auth:    &models.MCPAuthConfig{Type: models.AuthBasic, Username: "${env:MCP_TEST_TOKEN}", Password: "${secret:password}"},
+			},
+			secretsFile: secretsFile,
+			want: models.MCPServerConfig{
+				Name:    "Demo",
+				Headers: map[string]string{"X-Api-Key": "file-key", "X-Mixed": "key=env-token;v=1"},
+				Auth:    &models.MCPAuthConfig{Type: models.AuthBasic, Username: "env-token", Password: "p@ss"},
+			},
+		},
+		{
+			name:   "stdio env",
+			config: models.MCPServerConfig{Name: "Local", Transport: models.TransportStdio, Env: map[string]string{"TOKEN": "${env:MCP_TEST_TOKEN}"}},
+			want:   models.MCPServerConfig{Name: "Local", Transport: models.TransportStdio, Env: map[string]string{"TOKEN": "env-token"}},
+		},
+		{
+			name:        "env references do not read the secrets file",
+			config:      models.MCPServerConfig{Name: "Demo", Auth: &models.MCPAuthConfig{Type: models.AuthBearer, Token: "${env:MCP_TEST_TOKEN}"}},
+			secretsFile: filepath.Join(t.TempDir(), "missing.json"),
+			want:        models.MCPServerConfig{Name: "Demo", Auth: &models.MCPAuthConfig{Type: models.AuthBearer, Token: "env-token"}},
+		},
+		{
+			name:   "missing env",
+			config: models.MCPServerConfig{Name: "Demo", Headers: map[string]string{"X-Token": "${env:MCP_TEST_MISSING}"}},
+			err:    "环境变量 MCP_TEST_MISSING 未设置",
+		},
+		{
+			name:        "missing secret",
+			config:      models.MCPServerConfig{Name: "Demo", Auth: &models.MCPAuthConfig{Type: models.AuthBearer, Token: "${secret:missing}"}},
+			secretsFile: secretsFile,
+			err:         "密钥 missing 不存在",
+		},
+		{
+			name:   "no secrets file",
+			config: models.MCPServerConfig{Name: "Demo", Headers: map[string]string{"X-Api-Key": "${secret:api_key}"}},
+			err:    "mcp.secrets_file",
+		},
+		{
+			name:        "secrets file not found",
+			config:      models.MCPServerConfig{Name: "Demo", Headers: map[string]string{"X-Api-Key": "${secret:api_key}"}},
+			secretsFile: filepath.Join(t.TempDir(), "missing.json"),
+			err:         "读取密钥文件失败",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := tt.config
+			if original.Auth != nil {
+				auth := *original.Auth
+				original.Auth = &auth
+			}
+			got, err := resolveConfig(tt.config, tt.secretsFile)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("resolveConfig() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveConfig() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveConfig() = %+v, want %+v", got, tt.want)
+			}
+			// 解析得到的是副本，原配置中的引用保持不变
+			if !reflect.DeepEqual(tt.config, original) {
+				t.Errorf("resolveConfig() modified the config: %+v", tt.config)
+			}
+		})
+	}
+}
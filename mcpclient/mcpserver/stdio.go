@@ -0,0 +1,91 @@
+package mcpserver
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"mcpclient/models"
+)
+
+// 每个 stdio 服务器保留的 stderr 行数
+const stderrLines = 200
+
+// newStdioClient 启动子进程并创建 stdio 客户端
+// 子进程在客户端 Close 时退出：先关闭 stdin 等待进程自行退出，超时后依次发送 SIGTERM 和 SIGKILL
+func newStdioClient(config models.MCPServerConfig, opts []client.ClientOption) (*serverClient, error) {
+	if config.Command == "" {
+		return nil, fmt.Errorf("MCP 服务器 %s 没有配置 command", config.Name)
+	}
+
+	env := make([]string, 0, len(config.Env))
+	for key, value := range config.Env {
+		env = append(env, key+"="+value)
+	}
+
+	// 自己创建命令以便设置工作目录；不绑定 ctx，子进程的生命周期只由 Close 控制
+	stdio := transport.NewStdioWithOptions(config.Command, env, config.Args,
+		transport.WithCommandFunc(func(ctx context.Context, command string, env []string, args []string) (*exec.Cmd, error) {
+			cmd := exec.Command(command, args...)
+			cmd.Env = append(os.Environ(), env...)
+			cmd.Dir = config.Dir
+			return cmd, nil
+		}),
+	)
+	// 通过客户端启动，客户端才会在传输层注册通知和服务器请求（例如采样）的处理函数
+	mcpClient := client.NewClient(stdio, opts...)
+	if err := mcpClient.Start(context.Background()); err != nil {
+		return nil, fmt.Errorf("启动 MCP 服务器 %s 失败: %w", config.Name, err)
+	}
+
+	c := &serverClient{
+		Client: mcpClient,
+		config: config,
+		stderr: &lineBuffer{max: stderrLines},
+	}
+	go c.captureStderr(stdio.Stderr())
+	return c, nil
+}
+
+// captureStderr 读取子进程的 stderr，记录到日志并保留最近的内容，子进程退出后结束
+func (c *serverClient) captureStderr(stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		c.stderr.Add(line)
+		log.Debug("MCP 服务器 stderr", "server", c.config.Name, "line", line)
+	}
+	log.Debug("MCP 服务器 stderr 已关闭", "server", c.config.Name)
+}
+
+// lineBuffer 只保留最近若干行的缓冲区
+type lineBuffer struct {
+	mu    sync.Mutex
+	max   int
+	lines []string
+}
+
+// Add 追加一行，超过容量时丢弃最早的行
+func (b *lineBuffer) Add(line string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines = append(b.lines, line)
+	if len(b.lines) > b.max {
+		b.lines = append([]string(nil), b.lines[len(b.lines)-b.max:]...)
+	}
+}
+
+// Lines 返回缓冲区中所有行的副本
+func (b *lineBuffer) Lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.lines...)
+}
@@ -4,9 +4,11 @@ import (
 	"github.com/gin-gonic/gin"
 	"mcpclient/utils"
 	"net/http"
+	"strconv"
 )
 
-func AuthMiddleWare() gin.HandlerFunc {
+// 校验请求头中的 token，并把 token 中的用户信息放在ctx中，secretKey 为启动时读取的签名密钥
+func AuthMiddleWare(secretKey []byte) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		token := ctx.GetHeader("Authorization")
 		if token == "" {
@@ -14,14 +16,26 @@ func AuthMiddleWare() gin.HandlerFunc {
 			ctx.Abort()
 			return
 		}
-		username, err := utils.ParseJWT(token)
+		claims, err := utils.ParseJWT(secretKey, token)
 		if err != nil {
 			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			ctx.Abort()
 			return
 		}
 
-		ctx.Set("username", username)
+		// 用户身份只从 token 中获取，不信任请求体中的用户信息
+		ctx.Set("claims", claims)
+		ctx.Set("userid", strconv.FormatInt(claims.UserID, 10))
+		ctx.Set("username", claims.UserName)
+		ctx.Set("tier", claims.Tier)
+		ctx.Next()
+	}
+}
+
+// 将 jwt 签名密钥放在ctx中，登录时用它签发 token
+func LoadJWTSecret(secretKey []byte) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set("jwtSecret", secretKey)
 		ctx.Next()
 	}
 }
@@ -0,0 +1,95 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"mcpclient/models"
+	"mcpclient/utils"
+)
+
+var testSecret = []byte("test-secret")
+
+// signToken 使用 secretKey 签发一个在 expiresAt 过期的 token
+func signToken(t *testing.T, secretKey []byte, method jwt.SigningMethod, expiresAt time.Time) string {
+	t.Helper()
+	claims := &models.Claims{
+		UserID:   42,
+		UserName: "alice",
+		Tier:     "pro",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		},
+	}
+	token, err := jwt.NewWithClaims(method, claims).SignedString(secretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestAuthMiddleWare(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	valid, err := utils.GenerateToken(testSecret, 42, "alice", "pro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, &models.Claims{UserID: 42}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+		error  string // 期望的错误信息中包含的内容
+	}{
+		{name: "valid", header: valid, status: http.StatusOK},
+		{name: "valid with bearer prefix", header: "Bearer " + valid, status: http.StatusOK},
+		{name: "missing header", header: "", status: http.StatusUnauthorized, error: "Missing Authorization"},
+		{name: "expired", header: signToken(t, testSecret, jwt.SigningMethodHS256, time.Now().Add(-time.Minute)), status: http.StatusUnauthorized, error: "expired"},
+		{name: "wrong secret", header: signToken(t, []byte("other-secret"), jwt.SigningMethodHS256, time.Now().Add(time.Hour)), status: http.StatusUnauthorized, error: "signature is invalid"},
+		{name: "unsigned", header: unsigned, status: http.StatusUnauthorized},
+		{name: "malformed", header: "Bearer not-a-token", status: http.StatusUnauthorized, error: "malformed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", AuthMiddleWare(testSecret), func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, gin.H{
+					"userid":   ctx.GetString("userid"),
+					"username": ctx.GetString("username"),
+					"tier":     ctx.GetString("tier"),
+				})
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			body := w.Body.String()
+			if tt.status == http.StatusOK {
+				// 用户信息来自 token
+				if body != `{"tier":"pro","userid":"42","username":"alice"}` {
+					t.Errorf("body = %s", body)
+				}
+				return
+			}
+			if !strings.Contains(body, tt.error) {
+				t.Errorf("body = %s, want %q", body, tt.error)
+			}
+		})
+	}
+}
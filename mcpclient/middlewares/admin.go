@@ -0,0 +1,27 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"slices"
+)
+
+// 只允许管理员访问，需要放在 AuthMiddleWare 之后
+func AdminMiddleWare(admins []string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !slices.Contains(admins, ctx.GetString("userid")) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// 标记当前用户是否为管理员（isAdmin），用于管理员和普通用户都能访问但结果不同的接口，需要放在 AuthMiddleWare 之后
+func LoadAdmin(admins []string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set("isAdmin", slices.Contains(admins, ctx.GetString("userid")))
+		ctx.Next()
+	}
+}
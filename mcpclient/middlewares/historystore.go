@@ -0,0 +1,14 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"mcpclient/store"
+)
+
+// 将对话历史记录存储放在ctx中
+func LoadHistoryStore(historyStore store.HistoryStore) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set("historyStore", historyStore)
+		ctx.Next()
+	}
+}
@@ -0,0 +1,14 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"mcpclient/llm/routing"
+)
+
+// 将模型路由器放在ctx中
+func LoadLLMRouter(llmRouter *routing.Router) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set("llmRouter", llmRouter)
+		ctx.Next()
+	}
+}
@@ -0,0 +1,15 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"mcpclient/mcpserver"
+)
+
+// 将 MCP 客户端管理器和 MCP 服务器配置文件的路径放在ctx中
+func LoadMCPManager(manager *mcpserver.Manager, configPath string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set("mcpManager", manager)
+		ctx.Set("mcpConfigPath", configPath)
+		ctx.Next()
+	}
+}
@@ -2,17 +2,21 @@ package middlewares
 
 import (
 	"github.com/gin-gonic/gin"
-	"github.com/mark3labs/mcp-go/client"
 	"go.mongodb.org/mongo-driver/mongo"
 	"mcpclient/llm"
+	"mcpclient/mcpserver"
+	"mcpclient/utils"
 )
 
 // 将clients放在ctx中
+// 每个请求都从管理器中取当前健康的服务器的快照，不健康的服务器的工具不会提供给模型；
+// 快照在请求结束后释放，请求进行中配置被重新加载也不会关闭它正在使用的客户端
 func LoadMCPSSEconfig(provider llm.Provider,
-	ssemcpclients map[string]*client.SSEMCPClient,
-	allTools []llm.Tool,
+	manager *mcpserver.Manager,
 	mongodb *mongo.Collection) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		ssemcpclients, allTools, release := utils.AcquireClientsAndTools(manager)
+		defer release()
 		ctx.Set("provider", provider)
 		ctx.Set("clients", ssemcpclients)
 		ctx.Set("allTools", allTools)
@@ -0,0 +1,14 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"mcpclient/policy"
+)
+
+// 注入工具的使用策略
+func LoadToolPolicy(toolPolicy *policy.Policy) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set("toolPolicy", toolPolicy)
+		ctx.Next()
+	}
+}
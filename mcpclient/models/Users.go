@@ -11,6 +11,7 @@ type User struct {
 	Password     string    `gorm:"column:password;not null"`
 	Email        string    `gorm:"column:email"`
 	NickName     string    `gorm:"column:nickname"`
+	Tier         string    `gorm:"column:tier;default:free" json:"-"` // 用户等级只能由服务端设置，注册时不从请求体读取
 	RegisterTime time.Time `gorm:"column:registertime"`
 	ChangeTime   time.Time `gorm:"column:changetime"`
 	gorm.DeletedAt
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 对话响应中的流式事件类型
+const (
+	EventMessageDelta = "message.delta" // 模型输出的文本分片
+	EventToolCall     = "tool.call"     // 模型发起的工具调用
+	EventToolResult   = "tool.result"   // 工具调用的结果
+	EventToolApproval = "tool.approval" // 工具调用需要用户确认，确认或超时之前对话暂停
+	EventToolProgress = "tool.progress" // MCP 服务器发送的工具调用进度
+	EventServerLog    = "server.log"    // 工具调用期间 MCP 服务器发送的日志，只在服务器上没有其他进行中的工具调用时转发
+	EventUsage        = "usage"         // 一次模型请求的 token 使用情况
+	EventError        = "error"         // 对话出错，之后不会再有其他事件
+	EventDone         = "done"          // 对话正常结束
+)
+
+// Event 对话响应中的一个流式事件，以 SSE 的 event/data 帧发送给前端
+type Event struct {
+	Type           string             `json:"type"`
+	ConversationID string             `json:"conversation_id"`
+	MessageID      string             `json:"message_id,omitempty"`
+	Delta          string             `json:"delta,omitempty"`         // message.delta
+	ToolCall       *ToolCallEvent     `json:"tool_call,omitempty"`     // tool.call
+	ToolResult     *ToolResultEvent   `json:"tool_result,omitempty"`   // tool.result
+	ToolApproval   *ToolApprovalEvent `json:"tool_approval,omitempty"` // tool.approval
+	ToolProgress   *ToolProgressEvent `json:"tool_progress,omitempty"` // tool.progress
+	ServerLog      *ServerLogEvent    `json:"server_log,omitempty"`    // server.log
+	Usage          *UsageEvent        `json:"usage,omitempty"`         // usage
+	Error          string             `json:"error,omitempty"`         // error
+}
+
+// ToolCallEvent 工具调用事件的数据
+type ToolCallEvent struct {
+	ID        string                 `json:"id"`
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// ToolResultEvent 工具结果事件的数据
+type ToolResultEvent struct {
+	ToolCallID string `json:"tool_call_id"`
+	Name       string `json:"name"`
+	Content    string `json:"content"`
+	IsError    bool   `json:"is_error"`
+}
+
+// ToolApprovalEvent 工具调用确认事件的数据，通过 POST /api/mcp/approvals/:approval_id 同意、修改参数或拒绝
+type ToolApprovalEvent struct {
+	ApprovalID string                 `json:"approval_id"`
+	ToolCallID string                 `json:"tool_call_id"`
+	Name       string                 `json:"name"`
+	Arguments  map[string]interface{} `json:"arguments"`
+	ExpiresAt  time.Time              `json:"expires_at"` // 超过这个时间没有确认，工具调用按拒绝处理
+}
+
+// ToolProgressEvent 工具调用进度事件的数据
+type ToolProgressEvent struct {
+	ToolCallID string  `json:"tool_call_id"`
+	Name       string  `json:"name"`
+	Progress   float64 `json:"progress"`
+	Total      float64 `json:"total,omitempty"` // 为 0 表示总量未知
+	Message    string  `json:"message,omitempty"`
+}
+
+// ServerLogEvent MCP 服务器日志事件的数据
+type ServerLogEvent struct {
+	Server string `json:"server"`
+	Level  string `json:"level"`
+	Logger string `json:"logger,omitempty"`
+	Data   any    `json:"data"`
+}
+
+// UsageEvent token 使用情况事件的数据
+type UsageEvent struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// SSE 将事件编码为 SSE 帧：event 行为事件类型，data 行为 JSON
+func (e *Event) SSE() ([]byte, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", e.Type, data)), nil
+}
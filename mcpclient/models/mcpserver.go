@@ -0,0 +1,150 @@
+package models
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"time"
+)
+
+// MCP 服务器的传输方式
+const (
+	TransportSSE            = "sse"             // 通过 HTTP SSE 连接远程服务器（旧版协议）
+	TransportStreamableHTTP = "streamable-http" // 通过 Streamable HTTP 连接远程服务器
+	TransportStdio          = "stdio"           // 以子进程方式启动本地服务器，通过标准输入输出通信
+)
+
+// MCP 服务器发起采样请求（sampling/createMessage）时的处理策略
+const (
+	SamplingDeny    = "deny"    // 拒绝采样请求（默认）
+	SamplingAllow   = "allow"   // 直接使用客户端配置的模型执行
+	SamplingApprove = "approve" // 审批同意后执行：由正在这个服务器上调用工具的用户审批，无法确定用户时由管理员审批
+)
+
+// MCP 服务器的认证方式
+const (
+	AuthBearer = "bearer" // Authorization: Bearer <token>
+	AuthBasic  = "basic"  // Authorization: Basic base64(<username>:<password>)
+)
+
+// MCPServerConfig 定义 MCP 服务器的配置（ssemcpserver.json 中的一项）
+type MCPServerConfig struct {
+	Name      string `json:"name"`
+	Transport string `json:"transport,omitempty"` // 传输方式，默认为 sse
+
+	// sse 和 streamable-http 传输方式的配置
+	// sse 为服务器的基础地址（会自动拼接 /sse），streamable-http 为完整的 MCP 端点，例如 http://host/mcp
+	MCPServerURL string `json:"url,omitempty"`
+
+	// 连接远程服务器时附加的请求头和认证信息。
+	// 值中可以引用环境变量 ${env:NAME} 或者密钥文件中的密钥 ${secret:NAME}，连接时才解析，不要在配置文件中直接写明文
+	Headers map[string]string `json:"headers,omitempty"`
+	Auth    *MCPAuthConfig    `json:"auth,omitempty"`
+
+	// stdio 传输方式的配置
+	Command string            `json:"command,omitempty"` // 启动服务器的命令
+	Args    []string          `json:"args,omitempty"`    // 命令行参数
+	Env     map[string]string `json:"env,omitempty"`     // 额外的环境变量，会覆盖当前进程中的同名变量，值中同样可以引用 ${env:NAME} 和 ${secret:NAME}
+	Dir     string            `json:"dir,omitempty"`     // 工作目录，为空时使用当前目录
+
+	// 服务器请求采样时的处理策略：deny、allow 或 approve，默认为 deny。sse 传输方式不支持采样
+	Sampling string `json:"sampling,omitempty"`
+
+	// 转发给用户的服务器日志的最低级别（debug、info、notice、warning、error、critical、alert、emergency），默认为 info
+	LogLevel string `json:"log_level,omitempty"`
+
+	// 一次工具调用的超时时间，例如 30s、2m，为空时使用 mcp.tool_timeout
+	ToolTimeout string `json:"tool_timeout,omitempty"`
+
+	// 不能并行执行的工具名称，支持 * 等通配符，["*"] 表示服务器的所有工具。
+	// 这些工具执行时，同一轮中这个服务器的其他工具调用需要等待，多个这样的调用按模型给出的顺序依次执行
+	SerialTools []string `json:"serial_tools,omitempty"`
+}
+
+// MCPAuthConfig 远程 MCP 服务器的认证配置
+type MCPAuthConfig struct {
+	Type     string `json:"type"`               // bearer 或 basic
+	Token    string `json:"token,omitempty"`    // bearer 方式的 token
+	Username string `json:"username,omitempty"` // basic 方式的用户名
+	Password string `json:"password,omitempty"` // basic 方式的密码
+}
+
+// GetTransport 返回服务器的传输方式，未配置时为 sse
+func (c *MCPServerConfig) GetTransport() string {
+	if c.Transport == "" {
+		return TransportSSE
+	}
+	return c.Transport
+}
+
+// GetSampling 返回服务器的采样策略，未配置时为 deny
+func (c *MCPServerConfig) GetSampling() string {
+	if c.Sampling == "" {
+		return SamplingDeny
+	}
+	return c.Sampling
+}
+
+// GetLogLevel 返回服务器日志的最低级别，未配置时为 info
+func (c *MCPServerConfig) GetLogLevel() string {
+	if c.LogLevel == "" {
+		return "info"
+	}
+	return c.LogLevel
+}
+
+// GetToolTimeout 返回服务器单独配置的工具调用超时时间，未配置或者格式错误时为 0
+func (c *MCPServerConfig) GetToolTimeout() time.Duration {
+	timeout, _ := time.ParseDuration(c.ToolTimeout)
+	return timeout
+}
+
+// IsSerialTool 判断工具是否被配置为不能并行执行
+func (c *MCPServerConfig) IsSerialTool(name string) bool {
+	for _, pattern := range c.SerialTools {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted 返回可以写入日志的配置副本：请求头、认证信息和环境变量中的明文值被替换为 ***，url 中的密码被替换为 xxxxx，
+// ${env:NAME}、${secret:NAME} 这样的引用本身不是密钥，原样保留
+func (c MCPServerConfig) Redacted() MCPServerConfig {
+	c.Headers = redactValues(c.Headers)
+	c.Env = redactValues(c.Env)
+	if c.Auth != nil {
+		auth := *c.Auth
+		auth.Token = redactValue(auth.Token)
+		auth.Password = redactValue(auth.Password)
+		c.Auth = &auth
+	}
+	if u, err := url.Parse(c.MCPServerURL); err == nil {
+		c.MCPServerURL = u.Redacted()
+	}
+	return c
+}
+
+// redactValues 返回值被替换后的 map 副本
+func redactValues(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(values))
+	for key, value := range values {
+		redacted[key] = redactValue(value)
+	}
+	return redacted
+}
+
+// redactValue 只由引用组成的值原样返回，其他非空的值替换为 ***
+func redactValue(value string) string {
+	if value == "" || SecretReference.ReplaceAllString(value, "") == "" {
+		return value
+	}
+	return "***"
+}
+
+// SecretReference 匹配配置值中的 ${env:NAME} 和 ${secret:NAME} 引用，第一个分组为来源，第二个分组为名称
+var SecretReference = regexp.MustCompile(`\$\{(env|secret):([^}]*)\}`)
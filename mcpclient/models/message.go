@@ -4,13 +4,33 @@ import (
 	"mcpclient/llm/history"
 )
 
+// UserHistoryMessage 用户的一个对话，_id 为对话 ID（见 utils.GenerateCustomId）
 type UserHistoryMessage struct {
-	UserID         string                   `json:"userid"`
-	CreateTime     int64                    `json:"createtime"`
-	HistoryMessage []history.HistoryMessage `json:"historymessage"`
+	ConversationID string                   `json:"conversationid" bson:"_id"`
+	UserID         string                   `json:"userid" bson:"userid"`
+	Title          string                   `json:"title" bson:"title"`
+	CreateTime     int64                    `json:"createtime" bson:"createtime"`
+	UpdateTime     int64                    `json:"updatetime" bson:"updatetime"`
+	HistoryMessage []history.HistoryMessage `json:"historymessage" bson:"historymessage"`
+	DisabledTools  []string                 `json:"disabled_tools,omitempty" bson:"disabled_tools,omitempty"` // 对话中禁用的工具，格式为 服务器名称__工具名称 的 glob
 }
 
-type ManageHistoryMessage struct {
-	// 使用UserID+CreateTime作为key
-	Data map[string]*UserHistoryMessage
+// ConversationSummary 对话列表中的一项
+type ConversationSummary struct {
+	ConversationID string `json:"conversationid"`
+	Title          string `json:"title"`
+	CreateTime     int64  `json:"createtime"`
+	UpdateTime     int64  `json:"updatetime"`
+	MessageCount   int    `json:"messagecount"`
+}
+
+// Summary 返回对话的摘要信息
+func (m *UserHistoryMessage) Summary() ConversationSummary {
+	return ConversationSummary{
+		ConversationID: m.ConversationID,
+		Title:          m.Title,
+		CreateTime:     m.CreateTime,
+		UpdateTime:     m.UpdateTime,
+		MessageCount:   len(m.HistoryMessage),
+	}
 }
@@ -1,7 +1,20 @@
 package models
 
+import "mcpclient/llm"
+
 type Question struct {
-	Prompt     string `json:"prompt"`
-	Createtime int64  `json:"createtime"`
-	Userid     string `json:"userid"`
+	Prompt     string        `json:"prompt"`
+	Createtime int64         `json:"createtime"`
+	Model      string        `json:"model"`     // 请求使用的模型，必须在配置的白名单中，为空时按路由规则选择
+	Options    llm.Options   `json:"options"`   // 生成参数，覆盖模型的默认参数
+	Resources  []ResourceRef `json:"resources"` // 附加到本轮问题中的 MCP 资源，内容作为上下文发送给模型
+
+	// 转发到对话中的服务器日志的最低级别，key 为服务器名称；只能比服务器配置的级别更高
+	LogLevels map[string]string `json:"log_levels"`
+}
+
+// ResourceRef 引用某个 MCP 服务器上的一个资源
+type ResourceRef struct {
+	Server string `json:"server"`
+	URI    string `json:"uri"`
 }
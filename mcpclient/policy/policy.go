@@ -0,0 +1,184 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"mcpclient/config"
+	"mcpclient/llm"
+)
+
+// 规则的处理方式
+const (
+	ActionAllow   = "allow"
+	ActionDeny    = "deny"
+	ActionConfirm = "confirm" // 提供给模型，但每次调用前需要用户确认
+)
+
+// 规则中的角色：管理员为 admin，用户等级加上 tier: 前缀，例如 tier:pro，
+// 这样等级名称（例如注册为 admin 的等级）不会与管理员角色混淆
+const (
+	RoleAdmin      = "admin"
+	TierRolePrefix = "tier:"
+)
+
+// ErrToolDenied 工具被策略禁止使用
+var ErrToolDenied = errors.New("工具不允许使用")
+
+// Subject 使用工具的用户和对话
+type Subject struct {
+	Roles         []string // 用户的角色：tier:用户等级，管理员还有 admin，见 Roles
+	DisabledTools []string // 对话中禁用的工具，格式为 服务器名称__工具名称 的 glob，例如 Demo__*
+}
+
+// Policy 工具的使用策略
+// 规则按顺序匹配，第一条同时匹配服务器、工具和用户角色的规则决定是否允许使用、是否需要用户确认；
+// 没有规则匹配时使用默认处理方式。
+// 对话中禁用的工具在规则之后检查，只能进一步限制
+type Policy struct {
+	defaultAction string
+	rules         []config.ToolPolicyRule
+}
+
+// New 根据配置创建策略，检查处理方式和 glob 的格式
+func New(cfg config.ToolPolicyConfig) (*Policy, error) {
+	p := &Policy{defaultAction: cfg.Default, rules: cfg.Rules}
+	if p.defaultAction == "" {
+		p.defaultAction = ActionAllow
+	}
+	if !validAction(p.defaultAction) {
+		return nil, fmt.Errorf("工具策略的默认处理方式 %s 不受支持", cfg.Default)
+	}
+	for _, rule := range cfg.Rules {
+		if !validAction(rule.Action) {
+			return nil, fmt.Errorf("工具策略规则 %s 的处理方式 %s 不受支持", rule.Name, rule.Action)
+		}
+		for _, role := range rule.Roles {
+			if role != RoleAdmin && (!strings.HasPrefix(role, TierRolePrefix) || role == TierRolePrefix) {
+				return nil, fmt.Errorf("工具策略规则 %s 的角色 %s 不受支持，应为 admin 或 tier:用户等级", rule.Name, role)
+			}
+		}
+		for _, pattern := range append(slices.Clone(rule.Servers), rule.Tools...) {
+			if err := ValidatePattern(pattern); err != nil {
+				return nil, fmt.Errorf("工具策略规则 %s: %w", rule.Name, err)
+			}
+		}
+	}
+	return p, nil
+}
+
+// Roles 返回用户等级和是否为管理员对应的角色
+func Roles(tier string, admin bool) []string {
+	roles := []string{TierRolePrefix + tier}
+	if admin {
+		roles = append(roles, RoleAdmin)
+	}
+	return roles
+}
+
+// validAction 判断是否为支持的处理方式
+func validAction(action string) bool {
+	return action == ActionAllow || action == ActionDeny || action == ActionConfirm
+}
+
+// ValidatePattern 检查 glob 的格式
+func ValidatePattern(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("glob %s 格式错误: %w", pattern, err)
+	}
+	return nil
+}
+
+// Scope 绑定了用户和对话的策略，在一次对话请求中使用
+type Scope struct {
+	policy  *Policy
+	subject Subject
+}
+
+// Scope 返回绑定了用户和对话的策略
+func (p *Policy) Scope(subject Subject) *Scope {
+	return &Scope{policy: p, subject: subject}
+}
+
+// Allowed 检查用户在当前对话中能否使用服务器上的工具（包括需要确认的工具），不允许时返回包装了 ErrToolDenied 的错误
+func (s *Scope) Allowed(serverName, toolName string) error {
+	_, err := s.Check(serverName, toolName)
+	return err
+}
+
+// Check 返回用户在当前对话中使用服务器上的工具的处理方式：allow 或 confirm，不允许时返回包装了 ErrToolDenied 的错误
+// Scope 为 nil 时（没有配置策略）允许使用所有工具
+func (s *Scope) Check(serverName, toolName string) (string, error) {
+	if s == nil {
+		return ActionAllow, nil
+	}
+	action, rule := s.policy.defaultAction, "default"
+	for _, r := range s.policy.rules {
+		if matchAny(r.Servers, serverName) && matchAny(r.Tools, toolName) &&
+			(len(r.Roles) == 0 || slices.ContainsFunc(r.Roles, func(role string) bool {
+				return slices.Contains(s.subject.Roles, role)
+			})) {
+			action, rule = r.Action, r.Name
+			break
+		}
+	}
+	if action == ActionDeny {
+		return "", fmt.Errorf("%w: %s__%s（规则 %s）", ErrToolDenied, serverName, toolName, rule)
+	}
+
+	name := serverName + "__" + toolName
+	for _, pattern := range s.subject.DisabledTools {
+		if ok, _ := path.Match(pattern, name); ok {
+			return "", fmt.Errorf("%w: %s 在当前对话中已禁用", ErrToolDenied, name)
+		}
+	}
+	return action, nil
+}
+
+// Filter 返回允许使用的工具，工具名称的格式为 服务器名称__工具名称
+func (s *Scope) Filter(tools []llm.Tool) []llm.Tool {
+	if s == nil {
+		return tools
+	}
+	allowed := make([]llm.Tool, 0, len(tools))
+	for _, tool := range tools {
+		serverName, toolName, _ := strings.Cut(tool.Name, "__")
+		if err := s.Allowed(serverName, toolName); err != nil {
+			log.Debug("工具被策略过滤", "tool", tool.Name, "reason", err)
+			continue
+		}
+		allowed = append(allowed, tool)
+	}
+	return allowed
+}
+
+// matchAny 判断名称是否匹配任意一个 glob，没有 glob 时匹配所有名称
+func matchAny(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type scopeKey struct{}
+
+// WithScope 返回携带策略的 ctx，执行工具调用时再次检查
+func WithScope(ctx context.Context, scope *Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope)
+}
+
+// ScopeFromContext 返回 ctx 中的策略，没有时返回 nil（允许使用所有工具）
+func ScopeFromContext(ctx context.Context) *Scope {
+	scope, _ := ctx.Value(scopeKey{}).(*Scope)
+	return scope
+}
@@ -0,0 +1,132 @@
+package policy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"mcpclient/config"
+	"mcpclient/llm"
+)
+
+func TestCheck(t *testing.T) {
+	cfg := config.ToolPolicyConfig{
+		Default: ActionAllow,
+		Rules: []config.ToolPolicyRule{
+			{Name: "admin-write", Tools: []string{"write_*"}, Roles: []string{RoleAdmin}, Action: ActionConfirm},
+			{Name: "deny-write", Tools: []string{"write_*"}, Action: ActionDeny},
+			{Name: "pro-search", Servers: []string{"Search"}, Roles: []string{"tier:pro"}, Action: ActionAllow},
+			{Name: "deny-search", Servers: []string{"Search"}, Action: ActionDeny},
+			{Name: "confirm-shell", Servers: []string{"Shell*"}, Tools: []string{"run"}, Action: ActionConfirm},
+		},
+	}
+	tests := []struct {
+		name     string
+		cfg      *config.ToolPolicyConfig // 为空时使用 cfg
+		subject  Subject
+		server   string
+		tool     string
+		action   string // 期望的处理方式，为空表示不允许使用
+		denyRule string // 不允许使用时错误中的规则名称或原因
+	}{
+		{name: "default allow", subject: Subject{Roles: Roles("free", false)}, server: "Demo", tool: "hello", action: ActionAllow},
+		{name: "admin rule matches first", subject: Subject{Roles: Roles("free", true)}, server: "Demo", tool: "write_file", action: ActionConfirm},
+		{name: "later rule when role does not match", subject: Subject{Roles: Roles("pro", false)}, server: "Demo", tool: "write_file", denyRule: "deny-write"},
+		{name: "tier role", subject: Subject{Roles: Roles("pro", false)}, server: "Search", tool: "query", action: ActionAllow},
+		{name: "other tier", subject: Subject{Roles: Roles("free", false)}, server: "Search", tool: "query", denyRule: "deny-search"},
+		{name: "tier named admin is not admin", subject: Subject{Roles: Roles("admin", false)}, server: "Demo", tool: "write_file", denyRule: "deny-write"},
+		{name: "server and tool globs", subject: Subject{}, server: "ShellLocal", tool: "run", action: ActionConfirm},
+		{name: "tool glob does not match", subject: Subject{}, server: "ShellLocal", tool: "run_all", action: ActionAllow},
+		{name: "disabled in conversation", subject: Subject{DisabledTools: []string{"Demo__*"}}, server: "Demo", tool: "hello", denyRule: "已禁用"},
+		{name: "disabled tool does not match other servers", subject: Subject{DisabledTools: []string{"Demo__*"}}, server: "Other", tool: "hello", action: ActionAllow},
+		{name: "disabled after confirm rule", subject: Subject{Roles: Roles("", true), DisabledTools: []string{"*__write_*"}}, server: "Demo", tool: "write_file", denyRule: "已禁用"},
+		{
+			name:     "default deny",
+			cfg:      &config.ToolPolicyConfig{Default: ActionDeny, Rules: []config.ToolPolicyRule{{Name: "allow-demo", Servers: []string{"Demo"}, Action: ActionAllow}}},
+			server:   "Other",
+			tool:     "hello",
+			denyRule: "default",
+		},
+		{
+			name:   "default deny with matching rule",
+			cfg:    &config.ToolPolicyConfig{Default: ActionDeny, Rules: []config.ToolPolicyRule{{Name: "allow-demo", Servers: []string{"Demo"}, Action: ActionAllow}}},
+			server: "Demo",
+			tool:   "hello",
+			action: ActionAllow,
+		},
+		{
+			name:   "empty default is allow",
+			cfg:    &config.ToolPolicyConfig{},
+			server: "Demo",
+			tool:   "hello",
+			action: ActionAllow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cfg
+			if tt.cfg != nil {
+				c = *tt.cfg
+			}
+			p, err := New(c)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			action, err := p.Scope(tt.subject).Check(tt.server, tt.tool)
+			if tt.action != "" {
+				if err != nil || action != tt.action {
+					t.Fatalf("Check() = %q, %v, want %q", action, err, tt.action)
+				}
+				return
+			}
+			if !errors.Is(err, ErrToolDenied) {
+				t.Fatalf("Check() = %q, %v, want ErrToolDenied", action, err)
+			}
+			if !strings.Contains(err.Error(), tt.denyRule) {
+				t.Errorf("Check() error = %v, want %q", err, tt.denyRule)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name string
+		rule config.ToolPolicyRule
+		ok   bool
+	}{
+		{name: "valid", rule: config.ToolPolicyRule{Name: "r", Tools: []string{"write_*"}, Roles: []string{RoleAdmin, "tier:pro"}, Action: ActionDeny}, ok: true},
+		{name: "unknown action", rule: config.ToolPolicyRule{Name: "r", Action: "block"}},
+		{name: "bad glob", rule: config.ToolPolicyRule{Name: "r", Tools: []string{"[write"}, Action: ActionDeny}},
+		{name: "bare tier name", rule: config.ToolPolicyRule{Name: "r", Roles: []string{"pro"}, Action: ActionDeny}},
+		{name: "empty tier name", rule: config.ToolPolicyRule{Name: "r", Roles: []string{"tier:"}, Action: ActionDeny}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(config.ToolPolicyConfig{Rules: []config.ToolPolicyRule{tt.rule}})
+			if (err == nil) != tt.ok {
+				t.Errorf("New() error = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	p, err := New(config.ToolPolicyConfig{Rules: []config.ToolPolicyRule{
+		{Name: "deny-write", Tools: []string{"write_*"}, Action: ActionDeny},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tools := []llm.Tool{{Name: "Demo__read_file"}, {Name: "Demo__write_file"}, {Name: "Other__hello"}}
+	got := p.Scope(Subject{DisabledTools: []string{"Other__*"}}).Filter(tools)
+	if len(got) != 1 || got[0].Name != "Demo__read_file" {
+		t.Errorf("Filter() = %v, want only Demo__read_file", got)
+	}
+	// 没有配置策略时不过滤
+	var scope *Scope
+	if got := scope.Filter(tools); len(got) != len(tools) {
+		t.Errorf("nil Scope Filter() = %d tools, want %d", len(got), len(tools))
+	}
+}
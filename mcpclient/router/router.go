@@ -1,14 +1,20 @@
 package router
 
 import (
+	"context"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/mark3labs/mcp-go/client"
+	"go.mongodb.org/mongo-driver/mongo"
 	"log"
 	"mcpclient/config"
 	"mcpclient/controllers"
 	"mcpclient/llm"
+	"mcpclient/llm/routing"
+	"mcpclient/mcpserver"
 	"mcpclient/middlewares"
+	"mcpclient/models"
+	"mcpclient/policy"
+	"mcpclient/store"
 	"mcpclient/utils"
 	"time"
 )
@@ -20,34 +26,81 @@ func SetupRouter() *gin.Engine {
 	// 全局 CORS 中间件
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"}, // 允许所有域（可改为指定域名）
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour, // 预检请求缓存时间
 	}))
-	//r.Use(middlewares.AuthMiddleWare())
+	// jwt 签名密钥只在启动时读取一次
+	jwtSecret := jwtsecretconfig()
 	auth := r.Group("/api/auth")
+	auth.Use(middlewares.LoadJWTSecret(jwtSecret))
 	{
 		auth.POST("/login", controllers.Loginuser)
 		auth.POST("/register", controllers.RegisterUser)
 	}
 	// 注册中间件
-	provider, ssemcpclients, allTools := mcpseeconfig("/home/chenyun/program/Go/mcptest/mcpclient/config/ssemcpserver.json")
-	mongodb, err := config.ConnectMongoDB()
-	if err != nil {
-		log.Fatalln("连接MongoDB失败")
-	}
+	con := config.GetConfig()
+	mcpConfigPath := con.Getmcpserversfile()
+	provider := providerconfig()
+	llmRouter := llmrouterconfig(provider)
+	// MCP 服务器的采样请求也通过模型路由器选择模型
+	mcpManager := mcpmanagerconfig(mcpConfigPath, utils.NewSampler(llmRouter))
+	mongodb, historyStore := historystoreconfig()
+	toolPolicy := toolpolicyconfig()
 	// 注册路由
 	chat := r.Group("/api/chat")
-	chat.Use(middlewares.LoadMCPSSEconfig(provider, ssemcpclients, allTools, mongodb))
+	chat.Use(middlewares.AuthMiddleWare(jwtSecret))
+	chat.Use(middlewares.LoadMCPSSEconfig(provider, mcpManager, mongodb))
+	chat.Use(middlewares.LoadLLMRouter(llmRouter))
+	chat.Use(middlewares.LoadHistoryStore(historyStore))
+	chat.Use(middlewares.LoadMCPManager(mcpManager, mcpConfigPath))
+	chat.Use(middlewares.LoadAdmin(con.Getadminusers()))
+	chat.Use(middlewares.LoadToolPolicy(toolPolicy))
 	{
 		chat.POST("/send", controllers.HandleUserPrompt2)
+		// 对话管理
+		chat.GET("/conversations", controllers.ListConversations)
+		chat.GET("/conversations/:id", controllers.GetConversation)
+		chat.PATCH("/conversations/:id", controllers.UpdateConversation)
+		chat.DELETE("/conversations/:id", controllers.DeleteConversation)
+		chat.POST("/conversations/:id/title", controllers.GenerateConversationTitle)
+	}
+	// MCP 服务器状态、资源、提示模板、审批和配置管理
+	mcp := r.Group("/api/mcp")
+	mcp.Use(middlewares.AuthMiddleWare(jwtSecret))
+	mcp.Use(middlewares.LoadMCPManager(mcpManager, mcpConfigPath))
+	mcp.Use(middlewares.LoadAdmin(con.Getadminusers()))
+	{
+		mcp.GET("/servers", controllers.ListMCPServers)
+		// 资源
+		mcp.GET("/servers/:name/resources", controllers.ListMCPResources)
+		mcp.GET("/servers/:name/resources/read", controllers.ReadMCPResource)
+		// 提示模板
+		mcp.GET("/prompts", controllers.ListMCPPrompts)
+		// 等待用户审批的操作（例如服务器发起的采样请求）
+		mcp.GET("/approvals", controllers.ListMCPApprovals)
+		mcp.POST("/approvals/:id", controllers.DecideMCPApproval)
+		// 修改服务器配置需要管理员权限
+		admin := mcp.Group("")
+		admin.Use(middlewares.AdminMiddleWare(con.Getadminusers()))
+		admin.PUT("/servers", controllers.UpdateMCPServers)
+		admin.POST("/reload", controllers.ReloadMCPServers)
 	}
 	return r
 }
 
-func mcpseeconfig(path string) (llm.Provider, map[string]*client.SSEMCPClient, []llm.Tool) {
+// 读取 jwt 签名密钥，没有配置时无法签发和校验 token，直接退出
+func jwtsecretconfig() []byte {
+	jwtSecret, err := utils.JWTSecretKey()
+	if err != nil {
+		log.Fatalf("读取jwt签名密钥失败: %v", err)
+	}
+	return jwtSecret
+}
+
+func providerconfig() llm.Provider {
 	// 初始化服务
 	var modelFlag string
 	modelsource := "ollama:"
@@ -56,17 +109,70 @@ func mcpseeconfig(path string) (llm.Provider, map[string]*client.SSEMCPClient, [
 	modelFlag = modelsource + modelname
 	provider, err := utils.CreateProvider(modelFlag)
 	if err != nil {
-		log.Fatalln("创建模型提供者时出错: %v", err) // 创建失败则返回错误
+		log.Fatalf("创建模型提供者时出错: %v", err) // 创建失败则返回错误
 	}
+	return provider
+}
 
+func mcpmanagerconfig(path string, sampler mcpserver.Sampler) *mcpserver.Manager {
 	// 获取所有的mcpclients,allTools
 	// 获取所有的mcpclients
-	ssemcpconfigpath := "/home/chenyun/program/Go/mcptest/mcpclient/config/ssemcpserver.json"
-	ssemcpconfig, err := config.LoadMCPConfig(ssemcpconfigpath)
+	ssemcpconfig, err := config.LoadMCPConfig(path)
 	if err != nil {
 		log.Fatalln("读取mcpconfig失败", err)
 	}
-	ssemcpclients, allTools, err := utils.GetSSEMCPClientsandTools(ssemcpconfig)
+	// 启动 MCP 客户端管理器，连接失败的服务器在后台重连，不影响服务启动
+	mcpManager := utils.StartMCPManager(ssemcpconfig, sampler)
+
+	// 配置文件修改后自动重新加载，不需要重启服务
+	if _, err := config.WatchMCPConfig(path, func(servers []models.MCPServerConfig) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := mcpManager.Reload(ctx, servers); err != nil {
+			log.Println("重新加载 MCP 服务器配置失败:", err)
+		}
+	}); err != nil {
+		log.Println("监听 MCP 配置文件失败，修改配置后需要重启服务:", err)
+	}
+
+	return mcpManager
+}
 
-	return provider, ssemcpclients, allTools
+// 根据配置文件中的路由规则创建模型路由器
+func llmrouterconfig(provider llm.Provider) *routing.Router {
+	con := config.GetConfig()
+	_, modelname := con.Getollama()
+	llmRouter, err := utils.CreateRouter(provider, "ollama:"+modelname)
+	if err != nil {
+		log.Fatalf("创建模型路由器失败: %v", err)
+	}
+	return llmRouter
+}
+
+// 根据配置文件中的规则创建工具的使用策略
+func toolpolicyconfig() *policy.Policy {
+	con := config.GetConfig()
+	toolPolicy, err := policy.New(con.Gettoolpolicy())
+	if err != nil {
+		log.Fatalf("创建工具策略失败: %v", err)
+	}
+	return toolPolicy
+}
+
+// 根据配置文件创建对话历史记录存储，使用 mongo 存储时同时返回 MongoDB 集合
+func historystoreconfig() (*mongo.Collection, store.HistoryStore) {
+	con := config.GetConfig()
+	switch con.Gethistorystore() {
+	case "memory":
+		return nil, store.NewMemoryStore()
+	case "mongo":
+		mongodb, err := config.ConnectMongoDB()
+		if err != nil {
+			log.Fatalln("连接MongoDB失败", err)
+		}
+		return mongodb, store.NewMongoStore(mongodb)
+	default:
+		log.Fatalf("不支持的历史记录存储方式: %s", con.Gethistorystore())
+		return nil, nil
+	}
 }
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"mcpclient/llm/history"
+	"mcpclient/models"
+)
+
+// MemoryStore 保存在内存中的对话历史记录，服务重启后丢失，适用于开发和测试
+type MemoryStore struct {
+	mu            sync.RWMutex
+	conversations map[string]*models.UserHistoryMessage // 按对话 ID 索引
+}
+
+// NewMemoryStore 创建一个内存存储实例
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		conversations: make(map[string]*models.UserHistoryMessage),
+	}
+}
+
+// Get 获取用户的一个对话，返回的是副本
+func (s *MemoryStore) Get(ctx context.Context, userID, conversationID string) (*models.UserHistoryMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	conversation, ok := s.conversations[conversationID]
+	if !ok || conversation.UserID != userID {
+		return nil, ErrConversationNotFound
+	}
+	return copyConversation(conversation), nil
+}
+
+// Append 向对话追加消息，对话不存在时自动创建
+func (s *MemoryStore) Append(
+	ctx context.Context,
+	userID, conversationID string,
+	createTime int64,
+	messages ...history.HistoryMessage,
+) error {
+	normalized, err := normalizeMessages(messages)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conversation, ok := s.conversations[conversationID]
+	if !ok {
+		conversation = &models.UserHistoryMessage{
+			ConversationID: conversationID,
+			UserID:         userID,
+			CreateTime:     createTime,
+			HistoryMessage: []history.HistoryMessage{},
+		}
+		s.conversations[conversationID] = conversation
+	} else if conversation.UserID != userID {
+		return ErrConversationNotFound
+	}
+	conversation.HistoryMessage = append(conversation.HistoryMessage, normalized...)
+	conversation.UpdateTime = time.Now().Unix()
+	return nil
+}
+
+// List 按最后更新时间倒序返回用户的所有对话
+func (s *MemoryStore) List(ctx context.Context, userID string) ([]*models.UserHistoryMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var conversations []*models.UserHistoryMessage
+	for _, conversation := range s.conversations {
+		if conversation.UserID == userID {
+			conversations = append(conversations, copyConversation(conversation))
+		}
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].UpdateTime > conversations[j].UpdateTime
+	})
+	return conversations, nil
+}
+
+// Rename 修改对话的标题
+func (s *MemoryStore) Rename(ctx context.Context, userID, conversationID, title string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conversation, ok := s.conversations[conversationID]
+	if !ok || conversation.UserID != userID {
+		return ErrConversationNotFound
+	}
+	conversation.Title = title
+	return nil
+}
+
+// SetDisabledTools 设置对话中禁用的工具
+func (s *MemoryStore) SetDisabledTools(ctx context.Context, userID, conversationID string, patterns []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conversation, ok := s.conversations[conversationID]
+	if !ok || conversation.UserID != userID {
+		return ErrConversationNotFound
+	}
+	conversation.DisabledTools = append([]string(nil), patterns...)
+	return nil
+}
+
+// Delete 删除用户的一个对话
+func (s *MemoryStore) Delete(ctx context.Context, userID, conversationID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conversation, ok := s.conversations[conversationID]
+	if !ok || conversation.UserID != userID {
+		return ErrConversationNotFound
+	}
+	delete(s.conversations, conversationID)
+	return nil
+}
+
+// copyConversation 复制对话，避免调用方修改存储中的数据
+func copyConversation(conversation *models.UserHistoryMessage) *models.UserHistoryMessage {
+	cp := *conversation
+	cp.HistoryMessage = append([]history.HistoryMessage(nil), conversation.HistoryMessage...)
+	cp.DisabledTools = append([]string(nil), conversation.DisabledTools...)
+	return &cp
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"mcpclient/llm/history"
+)
+
+func message(role, text string) history.HistoryMessage {
+	return history.HistoryMessage{Role: role, Content: []history.ContentBlock{{Type: "text", Text: text}}}
+}
+
+// newTestStore 创建一个包含 alice 和 bob 各一个对话的存储
+func newTestStore(t *testing.T) *MemoryStore {
+	t.Helper()
+	s := NewMemoryStore()
+	ctx := context.Background()
+	if err := s.Append(ctx, "alice", "1-alice", 1, message("user", "你好")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(ctx, "bob", "1-bob", 1, message("user", "hi"), message("assistant", "hello")); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestMemoryStoreOwnerScoping(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		op   func(s *MemoryStore) error
+	}{
+		{name: "get", op: func(s *MemoryStore) error { _, err := s.Get(ctx, "alice", "1-bob"); return err }},
+		{name: "append", op: func(s *MemoryStore) error { return s.Append(ctx, "alice", "1-bob", 2, message("user", "x")) }},
+		{name: "rename", op: func(s *MemoryStore) error { return s.Rename(ctx, "alice", "1-bob", "mine") }},
+		{name: "disable tools", op: func(s *MemoryStore) error { return s.SetDisabledTools(ctx, "alice", "1-bob", []string{"*"}) }},
+		{name: "delete", op: func(s *MemoryStore) error { return s.Delete(ctx, "alice", "1-bob") }},
+		{name: "missing conversation", op: func(s *MemoryStore) error { return s.Rename(ctx, "alice", "2-alice", "x") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			if err := tt.op(s); !errors.Is(err, ErrConversationNotFound) {
+				t.Fatalf("error = %v, want ErrConversationNotFound", err)
+			}
+			// bob 的对话不受影响
+			conversation, err := s.Get(ctx, "bob", "1-bob")
+			if err != nil {
+				t.Fatalf("Get(bob) error = %v", err)
+			}
+			if conversation.Title != "" || len(conversation.DisabledTools) != 0 || len(conversation.HistoryMessage) != 2 {
+				t.Errorf("bob's conversation changed: %+v", conversation)
+			}
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	// 列表只包含自己的对话
+	conversations, err := s.List(ctx, "alice")
+	if err != nil || len(conversations) != 1 || conversations[0].ConversationID != "1-alice" {
+		t.Fatalf("List(alice) = %v, %v", conversations, err)
+	}
+
+	if err := s.Append(ctx, "alice", "1-alice", 99, message("assistant", "你好！")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Rename(ctx, "alice", "1-alice", "问候"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetDisabledTools(ctx, "alice", "1-alice", []string{"Demo__*"}); err != nil {
+		t.Fatal(err)
+	}
+	conversation, err := s.Get(ctx, "alice", "1-alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 创建时间只在创建对话时设置
+	if conversation.CreateTime != 1 || conversation.Title != "问候" || len(conversation.HistoryMessage) != 2 ||
+		len(conversation.DisabledTools) != 1 || conversation.UpdateTime == 0 {
+		t.Errorf("Get(alice) = %+v", conversation)
+	}
+
+	// 返回的是副本，修改它不影响存储
+	conversation.HistoryMessage[0].Role = "system"
+	conversation.DisabledTools[0] = "*"
+	again, _ := s.Get(ctx, "alice", "1-alice")
+	if again.HistoryMessage[0].Role != "user" || again.DisabledTools[0] != "Demo__*" {
+		t.Errorf("store was modified through a returned copy: %+v", again)
+	}
+
+	if err := s.Delete(ctx, "alice", "1-alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, "alice", "1-alice"); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("Get() after Delete() error = %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"mcpclient/llm/history"
+	"mcpclient/models"
+)
+
+// MongoStore 以 models.UserHistoryMessage 文档的形式把对话历史记录保存在 MongoDB 中
+// 文档的 _id 为对话 ID
+type MongoStore struct {
+	collection *mongo.Collection
+}
+
+// NewMongoStore 使用指定的集合创建一个 MongoDB 存储实例
+func NewMongoStore(collection *mongo.Collection) *MongoStore {
+	return &MongoStore{collection: collection}
+}
+
+// Get 获取用户的一个对话
+func (s *MongoStore) Get(ctx context.Context, userID, conversationID string) (*models.UserHistoryMessage, error) {
+	var conversation models.UserHistoryMessage
+	err := s.collection.FindOne(ctx, bson.M{"_id": conversationID, "userid": userID}).Decode(&conversation)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrConversationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := normalizeConversation(&conversation); err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// Append 向对话追加消息，对话不存在时自动创建
+func (s *MongoStore) Append(
+	ctx context.Context,
+	userID, conversationID string,
+	createTime int64,
+	messages ...history.HistoryMessage,
+) error {
+	normalized, err := normalizeMessages(messages)
+	if err != nil {
+		return err
+	}
+	_, err = s.collection.UpdateOne(ctx,
+		bson.M{"_id": conversationID, "userid": userID},
+		bson.M{
+			"$setOnInsert": bson.M{"createtime": createTime},
+			"$set":         bson.M{"updatetime": time.Now().Unix()},
+			"$push":        bson.M{"historymessage": bson.M{"$each": normalized}},
+		},
+		options.Update().SetUpsert(true),
+	)
+	// 对话 ID 已经被其他用户使用时，upsert 会因为 _id 冲突而失败
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrConversationNotFound
+	}
+	return err
+}
+
+// List 按最后更新时间倒序返回用户的所有对话
+func (s *MongoStore) List(ctx context.Context, userID string) ([]*models.UserHistoryMessage, error) {
+	cursor, err := s.collection.Find(ctx,
+		bson.M{"userid": userID},
+		options.Find().SetSort(bson.D{{Key: "updatetime", Value: -1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var conversations []*models.UserHistoryMessage
+	if err := cursor.All(ctx, &conversations); err != nil {
+		return nil, err
+	}
+	for _, conversation := range conversations {
+		if err := normalizeConversation(conversation); err != nil {
+			return nil, err
+		}
+	}
+	return conversations, nil
+}
+
+// Rename 修改对话的标题
+func (s *MongoStore) Rename(ctx context.Context, userID, conversationID, title string) error {
+	result, err := s.collection.UpdateOne(ctx,
+		bson.M{"_id": conversationID, "userid": userID},
+		bson.M{"$set": bson.M{"title": title}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrConversationNotFound
+	}
+	return nil
+}
+
+// SetDisabledTools 设置对话中禁用的工具
+func (s *MongoStore) SetDisabledTools(ctx context.Context, userID, conversationID string, patterns []string) error {
+	result, err := s.collection.UpdateOne(ctx,
+		bson.M{"_id": conversationID, "userid": userID},
+		bson.M{"$set": bson.M{"disabled_tools": patterns}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrConversationNotFound
+	}
+	return nil
+}
+
+// Delete 删除用户的一个对话
+func (s *MongoStore) Delete(ctx context.Context, userID, conversationID string) error {
+	result, err := s.collection.DeleteOne(ctx, bson.M{"_id": conversationID, "userid": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrConversationNotFound
+	}
+	return nil
+}
+
+// normalizeConversation 从数据库读出的 Content 是 bson 结构，转换为与 JSON 一致的通用结构
+func normalizeConversation(conversation *models.UserHistoryMessage) error {
+	messages, err := normalizeMessages(conversation.HistoryMessage)
+	if err != nil {
+		return err
+	}
+	conversation.HistoryMessage = messages
+	return nil
+}
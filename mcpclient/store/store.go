@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"mcpclient/llm/history"
+	"mcpclient/models"
+)
+
+// ErrConversationNotFound 对话不存在，或者不属于该用户
+var ErrConversationNotFound = errors.New("对话不存在")
+
+// HistoryStore 对话历史记录的存储接口
+// 所有操作都按用户和对话 ID 进行，一个用户只能访问自己的对话
+type HistoryStore interface {
+	// Get 获取用户的一个对话，不存在时返回 ErrConversationNotFound
+	Get(ctx context.Context, userID, conversationID string) (*models.UserHistoryMessage, error)
+
+	// Append 向对话追加消息，对话不存在时自动创建
+	// - createTime：对话的创建时间，只在创建对话时使用
+	Append(ctx context.Context, userID, conversationID string, createTime int64, messages ...history.HistoryMessage) error
+
+	// List 按最后更新时间倒序返回用户的所有对话
+	List(ctx context.Context, userID string) ([]*models.UserHistoryMessage, error)
+
+	// Rename 修改对话的标题，不存在时返回 ErrConversationNotFound
+	Rename(ctx context.Context, userID, conversationID, title string) error
+
+	// SetDisabledTools 设置对话中禁用的工具，不存在时返回 ErrConversationNotFound
+	SetDisabledTools(ctx context.Context, userID, conversationID string, patterns []string) error
+
+	// Delete 删除用户的一个对话，不存在时返回 ErrConversationNotFound
+	Delete(ctx context.Context, userID, conversationID string) error
+}
+
+// normalizeMessages 将消息通过 JSON 往返转换为通用结构
+// 工具结果块的 Content 可能是 []mcp.Content 或者从数据库中读出的 bson 结构，
+// 统一转换后保证存储和读取得到的内容与前端看到的 JSON 一致
+func normalizeMessages(messages []history.HistoryMessage) ([]history.HistoryMessage, error) {
+	if len(messages) == 0 {
+		return []history.HistoryMessage{}, nil
+	}
+	data, err := json.Marshal(messages)
+	if err != nil {
+		return nil, err
+	}
+	var normalized []history.HistoryMessage
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
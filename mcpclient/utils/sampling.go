@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	Log "github.com/charmbracelet/log"
+	"github.com/mark3labs/mcp-go/mcp"
+	"mcpclient/llm"
+	"mcpclient/llm/history"
+	"mcpclient/llm/routing"
+	"mcpclient/mcpserver"
+)
+
+// routerSampler 通过模型路由器执行 MCP 服务器的采样请求
+type routerSampler struct {
+	router *routing.Router
+}
+
+// NewSampler 创建使用模型路由器执行采样请求的 Sampler
+func NewSampler(router *routing.Router) mcpserver.Sampler {
+	return &routerSampler{router: router}
+}
+
+// CreateMessage 按服务器的模型偏好选择模型，使用请求中的 maxTokens、temperature、stopSequences 生成回复
+// 模型偏好只使用 hints，costPriority、speedPriority、intelligencePriority 不参与选择，没有匹配的提示时由路由规则决定；
+// 服务器请求包含的上下文（includeContext）不会提供，模型只能看到请求中的消息
+func (s *routerSampler) CreateMessage(ctx context.Context, serverName string, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	messages, err := samplingMessages(request.CreateMessageParams)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, decision, err := s.router.Select(ctx, s.preferredModel(request.ModelPreferences), routing.Request{
+		PromptLength: ContextLength(messages, ""),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	override := llm.Options{Stop: request.StopSequences}
+	if request.MaxTokens > 0 {
+		maxTokens := request.MaxTokens
+		override.MaxTokens = &maxTokens
+	}
+	// temperature 在请求中是可选字段，为 0 时无法区分是否设置，使用模型的默认值
+	if request.Temperature > 0 {
+		temperature := request.Temperature
+		override.Temperature = &temperature
+	}
+	opts := decision.Options.Merge(override)
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("采样参数错误: %w", err)
+	}
+	provider = provider.WithOptions(opts)
+
+	llmMessages := make([]llm.Message, len(messages))
+	for i := range messages {
+		llmMessages[i] = &messages[i]
+	}
+	Log.Debug("执行采样请求",
+		"server", serverName,
+		"provider", decision.Provider,
+		"messages", len(messages))
+	response, err := provider.CreateMessage(ctx, "", llmMessages, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mcp.CreateMessageResult{
+		SamplingMessage: mcp.SamplingMessage{
+			Role:    mcp.RoleAssistant,
+			Content: mcp.NewTextContent(strings.TrimSpace(response.GetContent())),
+		},
+		Model:      decision.Provider,
+		StopReason: "endTurn",
+	}, nil
+}
+
+// preferredModel 按服务器给出的模型提示依次在白名单中查找，返回第一个名称包含提示的模型
+// 没有提示或者都没有匹配时返回空字符串，由路由规则选择模型
+func (s *routerSampler) preferredModel(preferences *mcp.ModelPreferences) string {
+	if preferences == nil {
+		return ""
+	}
+	models := s.router.Models()
+	for _, hint := range preferences.Hints {
+		name := strings.ToLower(strings.TrimSpace(hint.Name))
+		if name == "" {
+			continue
+		}
+		for _, model := range models {
+			if strings.Contains(strings.ToLower(model), name) {
+				return model
+			}
+		}
+	}
+	return ""
+}
+
+// samplingMessages 将采样请求中的系统提示和消息转换为历史消息，只支持文本内容
+func samplingMessages(params mcp.CreateMessageParams) ([]history.HistoryMessage, error) {
+	messages := make([]history.HistoryMessage, 0, len(params.Messages)+1)
+	if params.SystemPrompt != "" {
+		messages = append(messages, history.HistoryMessage{
+			Role:    "system",
+			Content: []history.ContentBlock{{Type: "text", Text: params.SystemPrompt}},
+		})
+	}
+	for _, message := range params.Messages {
+		text, ok := mcp.AsTextContent(message.Content)
+		if !ok {
+			return nil, fmt.Errorf("采样请求中包含不支持的内容类型，只支持文本")
+		}
+		messages = append(messages, history.HistoryMessage{
+			Role:    string(message.Role),
+			Content: []history.ContentBlock{{Type: "text", Text: text.Text}},
+		})
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("采样请求中没有消息")
+	}
+	return messages, nil
+}
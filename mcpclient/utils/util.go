@@ -7,448 +7,684 @@ import (
 	"fmt"
 	Log "github.com/charmbracelet/log"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/mark3labs/mcp-go/client"
 	"github.com/mark3labs/mcp-go/mcp"
 	"golang.org/x/crypto/bcrypt"
-	"log"
 	"mcpclient/config"
 	"mcpclient/llm"
+	"mcpclient/llm/anthropic"
+	"mcpclient/llm/chain"
 	"mcpclient/llm/history"
+	"mcpclient/llm/mock"
 	"mcpclient/llm/ollama"
+	"mcpclient/llm/openai"
+	"mcpclient/llm/routing"
+	"mcpclient/mcpserver"
 	"mcpclient/models"
+	"mcpclient/policy"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 )
 
-const (
+// 模型过载时的重试间隔，测试中可以调小
+var (
 	initialBackoff = 1 * time.Second
 	maxBackoff     = 30 * time.Second
-	maxRetries     = 5 // 最多重试次数
 )
 
-var (
-	messageWindow int
+const (
+	maxRetries    = 5  // 最多重试次数
+	maxToolRounds = 10 // 一次对话中最多的工具调用轮数
 )
 
-// runPrompt 函数：负责发送用户输入的提示并处理 AI 模型的响应，
-// 并通过 Channel 将响应结果输出到外部。
+// RunPrompt 函数：Agent 循环，以流式方式发送用户输入的提示并处理 AI 模型的响应，
+// 文本分片、工具调用、工具结果和 token 使用情况以事件的形式实时写入 responseChan；
+// 模型在流中发起工具调用时，通过 MCP 客户端执行工具，把工具结果追加到历史记录后继续流式请求，
+// 直到模型给出最终回答。结束时写入 done 事件，出错时写入 error 事件。
 // 参数：
+// - ctx：context.Context，请求上下文
 // - provider：llm.Provider，负责与 AI 模型进行交互的提供程序
-// - mcpClients：map[string]*client.SSEMCPClient，MCP 客户端，用于工具调用
-// - tools：[]llm.Tool，支持的工具列表
+// - mcpClients：map[string]mcpserver.Client，MCP 客户端，用于工具调用
+// - tools：[]llm.Tool，支持的工具列表，为空时不使用工具
+// - conversationID：string，对话 ID，写入每个事件中
 // - prompt：string，用户输入的提示内容
+// - attachments：[]history.ContentBlock，附加到用户消息中的上下文，例如用户选择的资源内容，可以为空
 // - messages：*[]history.HistoryMessage，消息历史记录
-// - responseChan：chan<- string，输出到外部的 Channel
-func RunPromptmcp(
+// - responseChan：chan<- models.Event，输出到外部的 Channel，由调用方关闭
+func RunPrompt(
+	ctx context.Context, // 请求上下文，用于取消请求和传递追踪信息
 	provider llm.Provider, // llm 提供程序，处理 AI 模型请求
-	mcpClients map[string]*client.SSEMCPClient, // MCP 客户端，执行工具调用
+	mcpClients map[string]mcpserver.Client, // MCP 客户端，执行工具调用
 	tools []llm.Tool, // 支持的工具列表
+	conversationID string, // 对话 ID
 	prompt string, // 用户输入的提示
+	attachments []history.ContentBlock, // 附加到用户消息中的上下文
 	messages *[]history.HistoryMessage, // 消息历史记录
-	responseChan chan<- string,
+	responseChan chan<- models.Event, // 输出到外部的 Channel
+) error {
+	emitter := &eventEmitter{
+		ctx:            ctx,
+		ch:             responseChan,
+		conversationID: conversationID,
+	}
+	if err := runAgent(ctx, provider, mcpClients, tools, prompt, attachments, messages, emitter); err != nil {
+		emitter.emit(models.Event{Type: models.EventError, Error: err.Error()})
+		return err
+	}
+	emitter.emit(models.Event{Type: models.EventDone})
+	return nil
+}
+
+// eventEmitter 将 Agent 循环中产生的事件写入 responseChan，并补充对话 ID 和消息 ID
+type eventEmitter struct {
+	ctx            context.Context
+	ch             chan<- models.Event
+	conversationID string
+	messageID      string // 当前正在生成的助手消息 ID
+}
+
+// emit 写入一个事件，请求被取消（例如客户端断开）时直接丢弃，避免阻塞
+func (e *eventEmitter) emit(event models.Event) {
+	event.ConversationID = e.conversationID
+	if event.MessageID == "" {
+		event.MessageID = e.messageID
+	}
+	select {
+	case e.ch <- event:
+	case <-e.ctx.Done():
+	}
+}
+
+// tryEmit 写入一个可以丢弃的事件（进度、服务器日志），channel 已满时直接丢弃
+// 这些事件在 MCP 传输层的回调中产生，不能阻塞读取服务器消息
+func (e *eventEmitter) tryEmit(event models.Event) {
+	event.ConversationID = e.conversationID
+	if event.MessageID == "" {
+		event.MessageID = e.messageID
+	}
+	select {
+	case e.ch <- event:
+	default:
+	}
+}
+
+// runAgent Agent 循环的主体
+func runAgent(
+	ctx context.Context,
+	provider llm.Provider,
+	mcpClients map[string]mcpserver.Client,
+	tools []llm.Tool,
+	prompt string,
+	attachments []history.ContentBlock,
+	messages *[]history.HistoryMessage,
+	emitter *eventEmitter,
 ) error {
-	// 如果 prompt 为空（即工具调用的返回结果），显示用户输入的提示
+	// 提示词作为用户消息写入历史记录，之后只通过历史记录传给模型，避免重复
+	// 附加的上下文放在提示词之前，和提示词属于同一条用户消息
 	if prompt != "" {
+		content := append([]history.ContentBlock{}, attachments...)
+		content = append(content, history.ContentBlock{
+			Type: "text",
+			Text: prompt,
+		})
 		*messages = append(
 			*messages,
 			history.HistoryMessage{
-				Role: "user",
-				Content: []history.ContentBlock{{
-					Type: "text",
-					Text: prompt,
-				}},
+				Role:    "user",
+				Content: content,
 			},
 		)
 	}
 
-	var message llm.Message
-	var err error
-	backoff := initialBackoff // 初始重试间隔
-	retries := 0              // 重试次数
-
-	// 将 HistoryMessage 转换为 llm.Message
-	llmMessages := make([]llm.Message, len(*messages))
-	for i := range *messages {
-		llmMessages[i] = &(*messages)[i]
-	}
-
-	fmt.Println("进入重试机制")
-	// 重试机制：如果请求失败且是“过载”错误，则重试
-	for {
-		fmt.Printf("重试+++")
-		message, err = provider.CreateMessage(
-			context.Background(),
-			prompt,
-			llmMessages,
-			tools,
-		)
-		if err != nil {
-			// 检查是否为过载错误
-			fmt.Println(err)
-			if strings.Contains(err.Error(), "overloaded_error") {
-				// 如果重试次数已达最大值，返回错误
-				if retries >= maxRetries {
-					return fmt.Errorf(
-						"claude 当前过载，请稍等几分钟后再试",
-					)
-				}
-
-				Log.Warn("Ollama 过载，正在退避...",
-					"attempt", retries+1,
-					"backoff", backoff.String())
+	for round := 0; round < maxToolRounds; round++ {
+		// 将 HistoryMessage 转换为 llm.Message
+		llmMessages := make([]llm.Message, len(*messages))
+		for i := range *messages {
+			llmMessages[i] = &(*messages)[i]
+		}
 
-				// 退避策略：增加重试间隔
-				time.Sleep(backoff)
-				backoff *= 2
-				if backoff > maxBackoff {
-					backoff = maxBackoff
-				}
-				retries++
-				continue
+		// 每一轮模型响应对应一条新的助手消息，文本分片转换为 message.delta 事件
+		emitter.messageID = fmt.Sprintf("msg_%d", time.Now().UnixNano())
+		deltaChan := make(chan string, 10)
+		forwarded := make(chan struct{})
+		go func() {
+			defer close(forwarded)
+			for delta := range deltaChan {
+				emitter.emit(models.Event{Type: models.EventMessageDelta, Delta: delta})
 			}
-			// 如果不是过载错误，直接返回该错误
+		}()
+		message, err := createMessageStream(ctx, provider, llmMessages, tools, deltaChan)
+		close(deltaChan)
+		<-forwarded
+		if err != nil {
 			return err
 		}
-		// 如果没有出错，表示请求成功
-		break
-	}
-
-	var messageContent []history.ContentBlock
-	// 处理 AI 返回的文本内容
-	if message.GetContent() != "" {
-		// 将 AI 输出的内容写入 Channel，供外部程序使用
-		//responseChan <- message.GetContent() + "\n"
-		fmt.Println("处理 AI 输出的内容：" + message.GetContent() + "\n")
-		writeToChannel(responseChan, message.GetContent()+"\n")
-		// 将 AI 的文本内容存储在历史记录中
-		messageContent = append(messageContent, history.ContentBlock{
-			Type: "text",
-			Text: message.GetContent(),
-		})
-	}
-	toolResults := []history.ContentBlock{}
-	messageContent = []history.ContentBlock{}
-	// 处理工具调用
-	for _, toolCall := range message.GetToolCalls() {
-		fmt.Printf("处理 %s工具调用", toolCall.GetName())
-		Log.Info("🔧 使用工具", "name", toolCall.GetName())
-
-		input, _ := json.Marshal(toolCall.GetArguments()) // 序列化工具参数
-		messageContent = append(messageContent, history.ContentBlock{
-			Type:  "tool_use",
-			ID:    toolCall.GetID(),
-			Name:  toolCall.GetName(),
-			Input: input,
-		})
 
-		// 如果有使用统计，记录日志
+		// 如果有使用统计，记录日志并发送 usage 事件
 		inputTokens, outputTokens := message.GetUsage()
 		if inputTokens > 0 || outputTokens > 0 {
 			Log.Info("使用统计",
 				"input_tokens", inputTokens,
 				"output_tokens", outputTokens,
 				"total_tokens", inputTokens+outputTokens)
+			emitter.emit(models.Event{
+				Type: models.EventUsage,
+				Usage: &models.UsageEvent{
+					InputTokens:  inputTokens,
+					OutputTokens: outputTokens,
+				},
+			})
 		}
 
-		// 分割工具名称
-		parts := strings.Split(toolCall.GetName(), "__")
-		if len(parts) != 2 {
-			fmt.Printf(
-				"错误: 无效的工具名称格式: %s\n",
-				toolCall.GetName(),
-			)
-			continue
+		var messageContent []history.ContentBlock
+		if text := message.GetContent(); text != "" {
+			messageContent = append(messageContent, history.ContentBlock{
+				Type: "text",
+				Text: text,
+			})
 		}
 
-		serverName, toolName := parts[0], parts[1]
-		mcpClient, ok := mcpClients[serverName]
-		if !ok {
-			fmt.Printf("错误: 找不到服务器: %s\n", serverName)
-			continue
+		// 工具调用的 ID 在取出时生成，因此只取一次
+		toolCalls := message.GetToolCalls()
+		for _, toolCall := range toolCalls {
+			input, _ := json.Marshal(toolCall.GetArguments()) // 序列化工具参数
+			messageContent = append(messageContent, history.ContentBlock{
+				Type:  "tool_use",
+				ID:    toolCall.GetID(),
+				Name:  toolCall.GetName(),
+				Input: input,
+			})
+			emitter.emit(models.Event{
+				Type: models.EventToolCall,
+				ToolCall: &models.ToolCallEvent{
+					ID:        toolCall.GetID(),
+					Name:      toolCall.GetName(),
+					Arguments: toolCall.GetArguments(),
+				},
+			})
 		}
 
-		var toolArgs map[string]interface{}
-		if err := json.Unmarshal(input, &toolArgs); err != nil {
-			fmt.Printf("解析工具参数时出错: %v\n", err)
-			continue
+		if len(messageContent) == 0 {
+			Log.Warn("AI 输出内容为空")
+			return nil
 		}
 
-		var toolResultPtr *mcp.CallToolResult
-		// 调用工具
-		action := func() {
-			req := mcp.CallToolRequest{}
-			req.Params.Name = toolName
-			req.Params.Arguments = toolArgs
-			toolResultPtr, err = mcpClient.CallTool(
-				context.Background(),
-				req,
-			)
+		// 将 AI 响应消息添加到历史记录
+		role := message.GetRole()
+		if role == "" {
+			role = "assistant"
 		}
-		action()
+		*messages = append(*messages, history.HistoryMessage{
+			Role:    role,
+			Content: messageContent,
+		})
 
-		// 如果调用失败，显示错误信息并记录工具调用结果
-		if err != nil {
-			errMsg := fmt.Sprintf(
-				"调用工具 %s 时出错: %v",
-				toolName,
-				err,
-			)
-			fmt.Printf("调用工具出错：%s\n", errMsg)
-
-			// 添加错误信息作为工具调用结果
-			toolResults = append(toolResults, history.ContentBlock{
-				Type:      "tool_result",
-				ToolUseID: toolCall.GetID(),
-				Content: []history.ContentBlock{{
-					Type: "text",
-					Text: errMsg,
-				}},
-			})
-			continue
+		// 没有工具调用，说明模型已经给出最终回答
+		if len(toolCalls) == 0 {
+			return nil
 		}
 
-		toolResult := *toolResultPtr
+		// 执行工具调用，把结果作为用户消息写入历史记录后继续请求
+		toolResults := callTools(ctx, mcpClients, tools, toolCalls, emitter)
+		*messages = append(*messages, history.HistoryMessage{
+			Role:    "user",
+			Content: toolResults,
+		})
+	}
 
-		if toolResult.Content != nil {
+	return fmt.Errorf("工具调用超过 %d 轮，已停止", maxToolRounds)
+}
 
-			fmt.Println("原始工具结果内容", "content", toolResult.Content)
+// createMessageStream 以流式方式请求模型，遇到过载错误时退避重试
+// 已经输出了部分内容后才失败时不再重试，避免客户端收到重复的内容
+func createMessageStream(
+	ctx context.Context,
+	provider llm.Provider,
+	messages []llm.Message,
+	tools []llm.Tool,
+	responseChan chan<- string,
+) (llm.Message, error) {
+	backoff := initialBackoff // 初始重试间隔
+	retries := 0              // 重试次数
 
-			// 创建工具结果块
-			resultBlock := history.ContentBlock{
-				Type:      "tool_result",
-				ToolUseID: toolCall.GetID(),
-				Content:   toolResult.Content,
+	for {
+		// 通过中间通道转发内容，以便知道这次请求是否已经输出
+		proxy := make(chan string)
+		done := make(chan struct{})
+		emitted := false
+		go func() {
+			defer close(done)
+			for chunk := range proxy {
+				emitted = true
+				responseChan <- chunk
 			}
+		}()
+		message, err := provider.CreateMessagestream(
+			ctx,
+			"",
+			messages,
+			tools,
+			proxy,
+		)
+		close(proxy)
+		<-done
+		if err == nil {
+			return message, nil
+		}
 
-			// 提取文本内容
-			var resultText string
-			// 处理数组内容
-			for _, item := range toolResult.Content {
-				// 处理文本内容
-				if textContent, ok := item.(mcp.TextContent); ok {
-					//resultText += fmt.Sprintf("%v ", textContent.Text)
-					//responseChan <- resultText + "\n"
-					writeToChannel(responseChan, textContent.Text)
-				}
-				// 处理其他数据类型
-			}
+		// 如果不是过载错误，或者已经输出了部分内容，直接返回该错误
+		if !strings.Contains(err.Error(), "overloaded_error") || emitted {
+			return nil, err
+		}
+		// 如果重试次数已达最大值，返回错误
+		if retries >= maxRetries {
+			return nil, fmt.Errorf(
+				"%s 当前过载，请稍等几分钟后再试",
+				provider.Name(),
+			)
+		}
 
-			resultBlock.Text = strings.TrimSpace(resultText)
-			Log.Debug("创建工具结果块",
-				"block", resultBlock,
-				"tool_id", toolCall.GetID())
+		Log.Warn("模型过载，正在退避...",
+			"provider", provider.Name(),
+			"attempt", retries+1,
+			"backoff", backoff.String())
 
-			toolResults = append(toolResults, resultBlock)
+		// 退避策略：增加重试间隔
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		retries++
 	}
+}
 
-	fmt.Println("将 AI 响应消息添加到历史记录,如果没有工具调用，推出")
-	// 将 AI 响应消息添加到历史记录
-	*messages = append(*messages, history.HistoryMessage{
-		Role:    message.GetRole(),
-		Content: messageContent,
-	})
+// callTools 执行模型在一条消息中发起的所有工具调用，返回按调用顺序排列的 tool_result 内容块
+// 所有调用同时开始策略检查、参数校验和用户确认，之后最多同时执行 ToolExecution.Concurrency 个；
+// 服务器配置为不能并行的工具执行时独占这个服务器，同一服务器的其他调用需要等待。
+// 每个调用结束时立即发送 tool.result 事件，写入历史记录的结果顺序与调用顺序一致
+func callTools(
+	ctx context.Context,
+	mcpClients map[string]mcpserver.Client,
+	tools []llm.Tool,
+	toolCalls []llm.ToolCall,
+	emitter *eventEmitter,
+) []history.ContentBlock {
+	execution := mcpserver.ToolExecutionFromContext(ctx)
+	limiter := &toolLimiter{
+		execution: execution,
+		slots:     make(chan struct{}, execution.Concurrency),
+		servers:   make(map[string]*sync.RWMutex),
+	}
+	for _, toolCall := range toolCalls {
+		serverName, _, _ := strings.Cut(toolCall.GetName(), "__")
+		limiter.servers[serverName] = &sync.RWMutex{}
+	}
 
-	// 如果有工具结果，继续执行
-	if len(toolResults) > 0 {
-		fmt.Println("继续调用工具")
-		*messages = append(*messages, history.HistoryMessage{
-			Role:    "user",
-			Content: toolResults,
-		})
-		// 对工具结果再次调用 AI 获取响应
-		return RunPromptmcp(provider, mcpClients, tools, "", messages, responseChan)
+	results := make([]history.ContentBlock, len(toolCalls))
+	var wg sync.WaitGroup
+	for i, toolCall := range toolCalls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, isError := callTool(ctx, mcpClients, tools, toolCall, limiter, emitter)
+			results[i] = result
+			emitter.emit(models.Event{
+				Type: models.EventToolResult,
+				ToolResult: &models.ToolResultEvent{
+					ToolCallID: toolCall.GetID(),
+					Name:       toolCall.GetName(),
+					Content:    result.GetResultText(),
+					IsError:    isError,
+				},
+			})
+		}()
 	}
+	wg.Wait()
+	return results
+}
 
-	fmt.Println() // 输出换行
-	return nil
+// toolLimiter 限制一条消息中工具调用的并发数，并让配置为不能并行的工具独占服务器
+type toolLimiter struct {
+	execution mcpserver.ToolExecution
+	slots     chan struct{}
+	servers   map[string]*sync.RWMutex // 开始执行前按服务器名称创建好，之后只读
 }
 
-func RunPrompt(
-	provider llm.Provider, // llm 提供程序，处理 AI 模型请求
-	prompt string, // 用户输入的提示
-	messages *[]history.HistoryMessage, // 消息历史记录
-	responseChan chan<- string, // 输出到外部的 Channel
-) error {
-	// 如果 prompt 为空（即工具调用的返回结果），显示用户输入的提示
-	if prompt != "" {
-		*messages = append(
-			*messages,
-			history.HistoryMessage{
-				Role: "user",
-				Content: []history.ContentBlock{{
-					Type: "text",
-					Text: prompt,
-				}},
-			},
-		)
+// acquire 获取服务器锁和执行名额，返回释放函数
+// 先获取服务器锁再获取名额，等待独占服务器的调用不会占用名额
+func (l *toolLimiter) acquire(serverName string, serial bool) func() {
+	lock := l.servers[serverName]
+	unlock := lock.RUnlock
+	if serial {
+		lock.Lock()
+		unlock = lock.Unlock
+	} else {
+		lock.RLock()
 	}
+	l.slots <- struct{}{}
+	return func() {
+		<-l.slots
+		unlock()
+	}
+}
 
-	var message llm.Message
-	var err error
-	backoff := initialBackoff // 初始重试间隔
-	retries := 0              // 重试次数
-	tools := make([]llm.Tool, 0)
-	// 将 HistoryMessage 转换为 llm.Message
-	llmMessages := make([]llm.Message, len(*messages))
-	for i := range *messages {
-		llmMessages[i] = &(*messages)[i]
+// timeout 返回服务器的工具调用超时时间，服务器没有单独配置时使用默认值
+func (l *toolLimiter) timeout(mcpClient mcpserver.Client) time.Duration {
+	if t := mcpClient.ToolTimeout(); t > 0 {
+		return t
 	}
+	return l.execution.Timeout
+}
 
-	fmt.Println("进入重试机制")
-	// 重试机制：如果请求失败且是“过载”错误，则重试
-	for {
-		fmt.Printf("重试+++")
-		message, err = provider.CreateMessagestream(
-			context.Background(),
-			prompt,
-			llmMessages,
-			tools,
-			responseChan,
-		)
-		fmt.Println("调用完成", err)
-		if err != nil {
-			// 检查是否为过载错误
-			fmt.Println(err)
-			if strings.Contains(err.Error(), "overloaded_error") {
-				// 如果重试次数已达最大值，返回错误
-				if retries >= maxRetries {
-					return fmt.Errorf(
-						"claude 当前过载，请稍等几分钟后再试",
-					)
-				}
+// callTool 通过 MCP 客户端执行一次工具调用，返回对应的 tool_result 内容块以及调用是否出错
+// 调用期间服务器发送的进度和日志作为 tool.progress、server.log 事件写入 emitter；
+// 调用失败时把错误信息作为工具结果返回给模型，由模型决定下一步；
+// 参数在调用前按工具的输入 Schema 校验，不合法时不调用服务器，返回结构化的错误让模型修正后重试；
+// 用户确认之后才从 limiter 获取执行名额和服务器锁，调用超过超时时间时取消请求，把超时作为错误结果返回
+func callTool(
+	ctx context.Context,
+	mcpClients map[string]mcpserver.Client,
+	tools []llm.Tool,
+	toolCall llm.ToolCall,
+	limiter *toolLimiter,
+	emitter *eventEmitter,
+) (history.ContentBlock, bool) {
+	Log.Info("🔧 使用工具", "name", toolCall.GetName())
+
+	errorResult := func(errMsg string) (history.ContentBlock, bool) {
+		Log.Warn("调用工具出错", "name", toolCall.GetName(), "error", errMsg)
+		return history.ContentBlock{
+			Type:      "tool_result",
+			ToolUseID: toolCall.GetID(),
+			Text:      errMsg,
+			Content: []history.ContentBlock{{
+				Type: "text",
+				Text: errMsg,
+			}},
+		}, true
+	}
+
+	// 分割工具名称（格式为：服务器名称__工具名称）
+	parts := strings.Split(toolCall.GetName(), "__")
+	if len(parts) != 2 {
+		return errorResult(fmt.Sprintf("无效的工具名称格式: %s", toolCall.GetName()))
+	}
+
+	serverName, toolName := parts[0], parts[1]
+	// 模型可能调用没有提供给它的工具，执行前再按策略检查一次
+	action, err := policy.ScopeFromContext(ctx).Check(serverName, toolName)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+	mcpClient, ok := mcpClients[serverName]
+	if !ok {
+		return errorResult(fmt.Sprintf("找不到服务器: %s", serverName))
+	}
 
-				Log.Warn("Ollama 过载，正在退避...",
-					"attempt", retries+1,
-					"backoff", backoff.String())
+	// 参数不合法时直接返回，不需要用户确认
+	arguments := toolCall.GetArguments()
+	schema, hasSchema := findToolSchema(tools, toolCall.GetName())
+	if hasSchema {
+		if errs := schema.Validate(arguments); len(errs) > 0 {
+			return errorResult(invalidArgumentsMessage(toolCall.GetName(), errs))
+		}
+	}
 
-				// 退避策略：增加重试间隔
-				time.Sleep(backoff)
-				backoff *= 2
-				if backoff > maxBackoff {
-					backoff = maxBackoff
+	// 需要确认的工具先暂停，等待用户同意（可以修改参数）、拒绝或者超时
+	var note string
+	if action == policy.ActionConfirm {
+		decision, err := mcpserver.RequestApproval(ctx, mcpserver.Approval{
+			Kind:    "tool_call",
+			Server:  serverName,
+			Summary: fmt.Sprintf("调用工具 %s", toolCall.GetName()),
+			Detail: map[string]interface{}{
+				"conversation_id": emitter.conversationID,
+				"tool_call_id":    toolCall.GetID(),
+				"name":            toolCall.GetName(),
+				"arguments":       arguments,
+			},
+		}, func(approval mcpserver.Approval) {
+			emitter.emit(models.Event{
+				Type: models.EventToolApproval,
+				ToolApproval: &models.ToolApprovalEvent{
+					ApprovalID: approval.ID,
+					ToolCallID: toolCall.GetID(),
+					Name:       toolCall.GetName(),
+					Arguments:  arguments,
+					ExpiresAt:  approval.ExpiresAt,
+				},
+			})
+		})
+		switch {
+		case errors.Is(err, mcpserver.ErrApprovalTimeout):
+			return errorResult(fmt.Sprintf("等待用户确认超时，工具 %s 没有执行", toolCall.GetName()))
+		case err != nil:
+			return errorResult(fmt.Sprintf("工具 %s 需要用户确认: %v", toolCall.GetName(), err))
+		case !decision.Approved:
+			errMsg := fmt.Sprintf("用户拒绝了工具 %s 的调用", toolCall.GetName())
+			if decision.Reason != "" {
+				errMsg += "，原因：" + decision.Reason
+			}
+			return errorResult(errMsg)
+		}
+		Log.Info("用户确认了工具调用", "name", toolCall.GetName(), "edited", decision.Arguments != nil)
+		if decision.Arguments != nil {
+			if hasSchema {
+				if errs := schema.Validate(decision.Arguments); len(errs) > 0 {
+					return errorResult(invalidArgumentsMessage(toolCall.GetName(), errs))
 				}
-				retries++
-				continue
 			}
-			// 如果不是过载错误，直接返回该错误
-			return err
+			arguments = decision.Arguments
+			edited, _ := json.Marshal(arguments)
+			note = fmt.Sprintf("用户修改了调用参数，实际使用的参数为：%s", edited)
 		}
-		// 如果没有出错，表示请求成功
-		break
 	}
 
-	var messageContent []history.ContentBlock
+	// 等待用户确认期间不占用执行名额和服务器锁，超时时间也只计算实际的调用
+	release := limiter.acquire(serverName, mcpClient.SerialTool(toolName))
+	defer release()
+	timeout := limiter.timeout(mcpClient)
+
+	progressToken, stopObserving := mcpClient.Observe(mcpserver.Observer{
+		OnProgress: func(progress mcpserver.Progress) {
+			emitter.tryEmit(models.Event{
+				Type: models.EventToolProgress,
+				ToolProgress: &models.ToolProgressEvent{
+					ToolCallID: toolCall.GetID(),
+					Name:       toolCall.GetName(),
+					Progress:   progress.Progress,
+					Total:      progress.Total,
+					Message:    progress.Message,
+				},
+			})
+		},
+		OnLog: func(message mcpserver.LogMessage) {
+			emitter.tryEmit(models.Event{
+				Type: models.EventServerLog,
+				ServerLog: &models.ServerLogEvent{
+					Server: message.Server,
+					Level:  string(message.Level),
+					Logger: message.Logger,
+					Data:   message.Data,
+				},
+			})
+		},
+		MinLevel: mcpserver.LogLevelFromContext(ctx, serverName),
+		// 调用期间服务器发起的采样请求由当前用户审批
+		Owner: mcpserver.ApproverFromContext(ctx),
+	})
+	defer stopObserving()
+
+	req := mcp.CallToolRequest{}
+	req.Params.Name = toolName
+	req.Params.Arguments = arguments
+	req.Params.Meta = &mcp.Meta{ProgressToken: progressToken}
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	toolResult, err := mcpClient.CallTool(callCtx, req)
+	if err != nil {
+		if errors.Is(callCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			return errorResult(fmt.Sprintf("调用工具 %s 超时（超过 %s），已取消", toolName, timeout))
+		}
+		return errorResult(fmt.Sprintf("调用工具 %s 时出错: %v", toolName, err))
+	}
 
-	text := message.GetContent()
+	// 提取文本内容，供只支持纯文本工具结果的模型使用
+	var texts []string
+	for _, item := range toolResult.Content {
+		if textContent, ok := mcp.AsTextContent(item); ok {
+			texts = append(texts, textContent.Text)
+		}
+	}
 
-	if text != "" {
-		messageContent = append(messageContent, history.ContentBlock{
-			Type: "text",
-			Text: text,
-		})
-	} else {
-		fmt.Println("AI输出内容为空")
-		return nil
+	content := toolResult.Content
+	if note != "" {
+		// 告诉模型实际使用的参数，与历史记录中模型给出的参数区分
+		texts = append([]string{note}, texts...)
+		content = append([]mcp.Content{mcp.NewTextContent(note)}, content...)
 	}
-	// 将 AI 响应消息添加到历史记录
-	*messages = append(*messages, history.HistoryMessage{
-		Role:    message.GetRole(),
-		Content: messageContent,
-	})
 
-	return nil
+	resultBlock := history.ContentBlock{
+		Type:      "tool_result",
+		ToolUseID: toolCall.GetID(),
+		Text:      strings.TrimSpace(strings.Join(texts, "\n")),
+		Content:   content,
+	}
+	Log.Debug("创建工具结果块",
+		"block", resultBlock,
+		"tool_id", toolCall.GetID())
+	return resultBlock, toolResult.IsError
 }
 
-const chunkSize = 100
-
-func writeToChannel(responseChan chan<- string, resultText string) {
-	for len(resultText) > 0 {
-		fmt.Println("resultText", resultText)
-		if len(resultText) > chunkSize {
-			responseChan <- resultText[:chunkSize]
-			resultText = resultText[chunkSize:]
-			fmt.Println("----------")
-		} else {
-			responseChan <- resultText
-			fmt.Println("=========")
-			break
+// findToolSchema 返回提供给模型的同名工具的输入 Schema
+func findToolSchema(tools []llm.Tool, name string) (llm.Schema, bool) {
+	for _, tool := range tools {
+		if tool.Name == name {
+			return tool.InputSchema, true
 		}
 	}
+	return llm.Schema{}, false
 }
 
-func GetSSEMCPClientsandTools(
-	config []models.SSEServerConfig,
-) (map[string]*client.SSEMCPClient, []llm.Tool, error) {
+// invalidArgumentsMessage 生成参数校验失败时返回给模型的错误，使用 JSON 方便模型按位置逐个修正
+func invalidArgumentsMessage(name string, errs []llm.SchemaError) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"error":   "invalid_arguments",
+		"tool":    name,
+		"message": "参数不符合工具的输入定义，工具没有执行，请按 errors 修正参数后重新调用",
+		"errors":  errs,
+	})
+	return string(data)
+}
 
-	clients := make(map[string]*client.SSEMCPClient)
-	var allTools []llm.Tool
+// StartMCPManager 根据配置创建 MCP 客户端管理器并启动，等待所有服务器完成第一次连接尝试后返回
+// 连接失败的服务器会在后台按配置的退避时间重连；sampler 为 nil 时不支持服务器的采样请求
+func StartMCPManager(servers []models.MCPServerConfig, sampler mcpserver.Sampler) *mcpserver.Manager {
+	con := config.GetConfig()
+	opts := con.Getmcpmanager()
+	opts.Sampler = sampler
+	manager := mcpserver.NewManager(servers, opts)
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	manager.Start(ctx)
+	return manager
+}
 
-	// 遍历所有 MCP 服务配置
-	for _, server := range config {
-		// 创建 MCP 客户端
-		client, err := client.NewSSEMCPClient(server.MCPServerURL + "/sse")
-		if err != nil {
-			log.Fatalf("Failed to create client: %v", err)
-		}
-		defer client.Close()
+// AcquireClientsAndTools 返回当前所有健康的 MCP 服务器的客户端，以及转换后的工具列表
+// 不健康的服务器的工具不会提供给模型；使用完后需要调用 release，之后被淘汰的客户端才会关闭
+func AcquireClientsAndTools(manager *mcpserver.Manager) (map[string]mcpserver.Client, []llm.Tool, func()) {
+	snapshot := manager.Acquire()
 
-		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Second)
-		defer cancel()
+	// 按服务器名称排序，保证每次请求的工具顺序一致
+	names := make([]string, 0, len(snapshot.Tools))
+	for name := range snapshot.Tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-		// Start the client
-		if err := client.Start(ctx); err != nil {
-			log.Fatalf("Failed to start client: %v", err)
-		}
+	var allTools []llm.Tool
+	for _, name := range names {
+		allTools = append(allTools, McpToolsToAnthropicTools(name, snapshot.Tools[name])...)
+	}
+	return snapshot.Clients, allTools, snapshot.Release
+}
 
-		// Initialize
-		initRequest := mcp.InitializeRequest{}
-		initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
-		initRequest.Params.ClientInfo = mcp.Implementation{
-			Name:    "test-client",
-			Version: "1.0.0",
+// ResourceBlock 将用户选择的资源内容转换为附加到用户消息中的文本块
+// 文本内容原样放入，二进制内容模型无法直接使用，只保留类型说明
+func ResourceBlock(serverName, uri string, contents []mcp.ResourceContents) history.ContentBlock {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<resource server=%q uri=%q>\n", serverName, uri)
+	for _, item := range contents {
+		if text, ok := mcp.AsTextResourceContents(item); ok {
+			b.WriteString(text.Text)
+			b.WriteString("\n")
+		} else if blob, ok := mcp.AsBlobResourceContents(item); ok {
+			fmt.Fprintf(&b, "[二进制内容 %s，类型 %s，未附加]\n", blob.URI, blob.MIMEType)
 		}
+	}
+	b.WriteString("</resource>")
+	return history.ContentBlock{
+		Type: "text",
+		Text: b.String(),
+	}
+}
 
-		result, err := client.Initialize(ctx, initRequest)
-		if err != nil {
-			log.Fatalf("Failed to initialize: %v", err)
+// PromptMessages 将 MCP 提示模板展开后的消息转换为历史消息，作为本轮问题发送给模型
+// 附加的上下文放在最后一条用户消息的开头，没有用户消息时单独作为一条用户消息
+func PromptMessages(serverName string, result *mcp.GetPromptResult, attachments []history.ContentBlock) []history.HistoryMessage {
+	messages := make([]history.HistoryMessage, 0, len(result.Messages)+1)
+	lastUser := -1
+	for _, message := range result.Messages {
+		var block history.ContentBlock
+		if text, ok := mcp.AsTextContent(message.Content); ok {
+			block = history.ContentBlock{Type: "text", Text: text.Text}
+		} else if resource, ok := mcp.AsEmbeddedResource(message.Content); ok {
+			var uri string
+			if text, ok := mcp.AsTextResourceContents(resource.Resource); ok {
+				uri = text.URI
+			} else if blob, ok := mcp.AsBlobResourceContents(resource.Resource); ok {
+				uri = blob.URI
+			}
+			block = ResourceBlock(serverName, uri, []mcp.ResourceContents{resource.Resource})
+		} else {
+			// 图片和音频内容模型无法通过文本使用
+			block = history.ContentBlock{Type: "text", Text: "[提示模板中的非文本内容，未附加]"}
 		}
 
-		fmt.Printf("获取服务名称：" + result.ServerInfo.Name + " ")
-
-		// Test Ping
-		if err := client.Ping(ctx); err != nil {
-			log.Fatalln("Ping failed: %v", err)
+		role := string(message.Role)
+		// 连续的同角色消息合并为一条
+		if n := len(messages); n > 0 && messages[n-1].Role == role {
+			messages[n-1].Content = append(messages[n-1].Content, block)
+		} else {
+			messages = append(messages, history.HistoryMessage{
+				Role:    role,
+				Content: []history.ContentBlock{block},
+			})
 		}
-		// 获取Tools
-		ctx2, cancel2 := context.WithTimeout(context.Background(), 1000*time.Second)
-
-		//mcpClient.Start(ctx)
-		toolsResult, err := client.ListTools(ctx2, mcp.ListToolsRequest{})
-		cancel2() // 取消请求
-
-		if err != nil {
-			fmt.Println(
-				"获取工具列表时出错",
-				"服务器", server.Name,
-				"错误", err,
-			)
-			continue // 获取工具失败则跳过
+		if role == string(mcp.RoleUser) {
+			lastUser = len(messages) - 1
 		}
-
-		// 将 MCP 工具转换为符合标准的工具列表
-		serverTools := McpToolsToAnthropicTools(server.Name, toolsResult.Tools)
-		allTools = append(allTools, serverTools...) // 合并工具
-		Log.Info(
-			"工具加载成功",
-			"服务器", server.Name,
-			"工具数量", len(toolsResult.Tools),
-		)
-		// 保存客户端到映射中
-		clients[server.Name] = client
 	}
 
-	return clients, allTools, nil
+	if len(attachments) == 0 {
+		return messages
+	}
+	if lastUser < 0 {
+		return append(messages, history.HistoryMessage{
+			Role:    string(mcp.RoleUser),
+			Content: append([]history.ContentBlock{}, attachments...),
+		})
+	}
+	content := append([]history.ContentBlock{}, attachments...)
+	messages[lastUser].Content = append(content, messages[lastUser].Content...)
+	return messages
 }
 
 // 将 MCP 工具列表转换为 Anthropic 工具格式
@@ -472,6 +708,9 @@ func McpToolsToAnthropicTools(
 				Type:       tool.InputSchema.Type,
 				Properties: tool.InputSchema.Properties,
 				Required:   tool.InputSchema.Required,
+
+				AdditionalProperties: tool.InputSchema.AdditionalProperties,
+				Defs:                 tool.InputSchema.Defs,
 			},
 		}
 	}
@@ -498,103 +737,125 @@ func CreateProvider(modelString string) (llm.Provider, error) {
 	case "ollama":
 		return ollama.NewProvider(model)
 
+	case "openai":
+		con := config.GetConfig()
+		baseURL, apiKey := con.Getopenai()
+		return openai.NewProvider(baseURL, apiKey, model)
+
+	case "anthropic":
+		con := config.GetConfig()
+		baseURL, apiKey, maxTokens := con.Getanthropic()
+		return anthropic.NewProvider(baseURL, apiKey, model, maxTokens)
+
+	case "mock":
+		// mock 提供者的模型部分为回放脚本的路径，例如 mock:config/mockscript.yaml
+		return mock.NewProviderFromFile(model)
+
+	case "chain":
+		// 组合提供者，成员之间用逗号分隔，例如 chain:ollama:gemma3:1b,openai:gpt-4o-mini
+		var members []llm.Provider
+		for _, member := range strings.Split(model, ",") {
+			p, err := CreateProvider(strings.TrimSpace(member))
+			if err != nil {
+				return nil, fmt.Errorf("创建组合提供者成员 %s 失败: %w", member, err)
+			}
+			members = append(members, p)
+		}
+		con := config.GetConfig()
+		return chain.NewProvider(members, llm.ParseErrorClasses(con.Getrouting().FallbackOn))
+
 	default:
 		return nil, fmt.Errorf("不支持的提供商：%s", provider)
 	}
 }
 
-// 修剪消息历史记录，确保不会超过指定的消息窗口大小
-func PruneMessages(messages []history.HistoryMessage) []history.HistoryMessage {
-	if len(messages) <= messageWindow {
-		return messages
-	}
-
-	// 保留最近的消息
-	messages = messages[len(messages)-messageWindow:]
-
-	// 处理消息中的工具使用和工具结果
-	toolUseIds := make(map[string]bool)
-	toolResultIds := make(map[string]bool)
+// CreateRouter 根据配置文件中的路由规则创建模型路由器
+// - provider：没有规则匹配时使用的默认提供者
+// - modelFlag：默认提供者的配置，例如 ollama:gemma3:1b
+func CreateRouter(provider llm.Provider, modelFlag string) (*routing.Router, error) {
+	con := config.GetConfig()
+	return routing.NewRouter(con.Getrouting(), con.Getmodels(), provider, modelFlag, CreateProvider)
+}
 
-	// 第一次遍历：收集所有工具使用和结果的ID
-	for _, msg := range messages {
-		for _, block := range msg.Content {
-			if block.Type == "tool_use" {
-				toolUseIds[block.ID] = true
-			} else if block.Type == "tool_result" {
-				toolResultIds[block.ToolUseID] = true
-			}
-		}
+// ContextLength 计算本轮请求的上下文长度（历史消息、提示词和附加内容的字符数），用于路由决策
+func ContextLength(messages []history.HistoryMessage, prompt string, attachments ...history.ContentBlock) int {
+	length := utf8.RuneCountInString(prompt)
+	for _, block := range attachments {
+		length += utf8.RuneCountInString(block.Text)
+	}
+	for i := range messages {
+		length += utf8.RuneCountInString(messages[i].GetContent())
 	}
+	return length
+}
 
-	// 第二次遍历：过滤掉孤立的工具调用/结果
-	var prunedMessages []history.HistoryMessage
-	for _, msg := range messages {
-		var prunedBlocks []history.ContentBlock
-		for _, block := range msg.Content {
-			keep := true
-			if block.Type == "tool_use" {
-				keep = toolResultIds[block.ID]
-			} else if block.Type == "tool_result" {
-				keep = toolUseIds[block.ToolUseID]
-			}
-			if keep {
-				prunedBlocks = append(prunedBlocks, block)
-			}
+// 生成对话标题的提示词和标题的最大长度
+const (
+	titlePrompt    = "请根据下面的对话，用不超过 20 个字概括对话的主题作为标题。只输出标题本身，不要加引号、标点或任何解释。"
+	maxTitleLength = 30
+)
+
+// GenerateTitle 根据对话的第一轮问答，使用模型生成对话标题
+// 模型调用失败或者返回为空时，使用第一条用户消息的开头作为标题
+func GenerateTitle(ctx context.Context, provider llm.Provider, messages []history.HistoryMessage) (string, error) {
+	var question, answer string
+	for i := range messages {
+		text := messages[i].GetContent()
+		if text == "" {
+			continue
 		}
-		// 仅包含有内容的消息，或者不是助手消息
-		if (len(prunedBlocks) > 0 && msg.Role == "assistant") ||
-			msg.Role != "assistant" {
-			hasTextBlock := false
-			for _, block := range msg.Content {
-				if block.Type == "text" {
-					hasTextBlock = true
-					break
-				}
-			}
-			if len(prunedBlocks) > 0 || hasTextBlock {
-				msg.Content = prunedBlocks
-				prunedMessages = append(prunedMessages, msg)
-			}
+		if question == "" && messages[i].Role == "user" {
+			question = text
+		} else if question != "" && messages[i].Role == "assistant" {
+			answer = text
+			break
 		}
 	}
-	return prunedMessages
-}
-func Getproviderclientstools() (llm.Provider, map[string]*client.SSEMCPClient, []llm.Tool, error) {
-	// 初始化服务
-	var modelFlag string
-	modelsource := "ollama:"
-	con := config.GetConfig()
-	_, modelname := con.Getollama()
-	modelFlag = modelsource + modelname
-	provider, err := CreateProvider(modelFlag)
-	if err != nil {
-		log.Fatalln("创建模型提供者时出错: %v", err) // 创建失败则返回错误
+	if question == "" {
+		return "", errors.New("对话中没有用户消息，无法生成标题")
 	}
 
-	// 获取所有的mcpclients,allTools
-	// 获取所有的mcpclients
-	ssemcpconfigpath := "/home/chenyun/program/Go/mcptest/mcpclient/config/ssemcpserver.json"
-	ssemcpconfig, err := config.LoadMCPConfig(ssemcpconfigpath)
+	prompt := fmt.Sprintf("%s\n\n用户：%s\n助手：%s", titlePrompt, question, answer)
+	message, err := provider.CreateMessage(ctx, prompt, nil, nil)
 	if err != nil {
-		log.Fatalln("读取mcpconfig失败", err)
+		Log.Warn("生成对话标题失败，使用第一条消息作为标题", "error", err)
+		return truncateTitle(question), nil
 	}
-	ssemcpclients, allTools, err := GetSSEMCPClientsandTools(ssemcpconfig)
-	// 添加mcpclients,allTools
-	return provider, ssemcpclients, allTools, nil
+	title := truncateTitle(message.GetContent())
+	if title == "" {
+		return truncateTitle(question), nil
+	}
+	return title, nil
+}
+
+// truncateTitle 只保留标题的第一行，去掉两端的引号，并截断到最大长度
+func truncateTitle(title string) string {
+	title = strings.TrimSpace(title)
+	if i := strings.IndexAny(title, "\r\n"); i >= 0 {
+		title = title[:i]
+	}
+	title = strings.Trim(strings.TrimSpace(title), "\"'“”‘’《》「」。.")
+	runes := []rune(title)
+	if len(runes) > maxTitleLength {
+		return string(runes[:maxTitleLength]) + "…"
+	}
+	return string(runes)
 }
 
 // 生成自定义的 _id
 // userid 学号 3220921037
 func GenerateCustomId(timestamp int64, userid string) string {
-	// 拼接所有部分：时间戳（秒级） + 业务字符串 + 计数器
-	id := fmt.Sprintf("%d-%s-%d", timestamp, userid)
+	// 拼接所有部分：时间戳（秒级） + 用户 ID
+	id := fmt.Sprintf("%d-%s", timestamp, userid)
 	return id
 }
-func GenerateToken(userID int64, username string) (string, error) {
+
+// GenerateToken 使用 secretKey 签发包含用户信息的 token，secretKey 在启动时从配置文件中读取
+func GenerateToken(secretKey []byte, userID int64, username string, tier string) (string, error) {
 	claims := &models.Claims{
 		UserID:   userID,
 		UserName: username,
+		Tier:     tier,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 12)), // 设置过期时间为 12 小时后
 			IssuedAt:  jwt.NewNumericDate(time.Now()),                     // 设置签发时间
@@ -606,12 +867,8 @@ func GenerateToken(userID int64, username string) (string, error) {
 	// 使用指定的签名方式获取Token
 	Token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
-	// 从配置文件中获取jwtSecret
-	con := config.GetConfig()
-	jwtSecret := con.GetsecretKey()
-
-	// 使用密钥签名 Token 并获取完整编码后的字符串 token
-	signedToken, err := Token.SignedString(jwtSecret)
+	// 使用密钥签名 Token 并获取完整编码后的字符串 token，HMAC 签名要求密钥为 []byte
+	signedToken, err := Token.SignedString(secretKey)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
@@ -619,31 +876,33 @@ func GenerateToken(userID int64, username string) (string, error) {
 	return signedToken, nil
 }
 
-func ParseJWT(tokenString string) (string, error) {
-	con := config.GetConfig()
-	secertKey := con.GetsecretKey()
-
-	if len(tokenString) > 7 && tokenString[:7] == "Bearer " {
-		tokenString = tokenString[7:]
-	}
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+// ParseJWT 使用 secretKey 校验 token 并返回其中的用户信息，token 可以带有 "Bearer " 前缀
+func ParseJWT(secretKey []byte, tokenString string) (*models.Claims, error) {
+	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
+	claims := &models.Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("Unexpected Signing Method")
 		}
-		return []byte(secertKey), nil
+		return secretKey, nil
 	})
 	if err != nil { // 错误
-		fmt.Println("err", err)
-		return "", err
+		return nil, err
 	}
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		username, ok := claims["username"].(string)
-		if !ok {
-			return "", errors.New("Username claim is not a string")
-		}
-		return username, nil
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// JWTSecretKey 获取配置文件中的 jwt 签名密钥，在启动时读取一次，签名和校验必须使用同一个密钥
+func JWTSecretKey() ([]byte, error) {
+	con := config.GetConfig()
+	secretKey := con.GetsecretKey()
+	if secretKey == "" {
+		return nil, errors.New("jwt.secret_key is not configured")
 	}
-	return "", err
+	return []byte(secretKey), nil
 }
 func GetHashPassword(password string) (string, error) {
 	hashpassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
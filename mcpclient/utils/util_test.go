@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"mcpclient/config"
+	"mcpclient/llm"
+	"mcpclient/llm/history"
+	"mcpclient/llm/mock"
+	"mcpclient/mcpserver"
+	"mcpclient/models"
+	"mcpclient/policy"
+)
+
+// fakeClient 只实现工具调用的 MCP 客户端，CallTool 返回参数中 name 的问候语
+type fakeClient struct {
+	mcpserver.Client
+	timeout time.Duration
+
+	mu    sync.Mutex
+	calls []mcp.CallToolRequest
+}
+
+func (c *fakeClient) CallTool(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, req)
+	c.mu.Unlock()
+	name, _ := req.GetArguments()["name"].(string)
+	if name == "" {
+		return nil, errors.New("missing name")
+	}
+	return mcp.NewToolResultText("hello " + name), nil
+}
+
+func (c *fakeClient) Observe(mcpserver.Observer) (mcp.ProgressToken, func()) {
+	return "token", func() {}
+}
+
+func (c *fakeClient) ToolTimeout() time.Duration { return c.timeout }
+
+func (c *fakeClient) SerialTool(string) bool { return false }
+
+var helloTool = llm.Tool{
+	Name:        "Demo__hello",
+	Description: "say hello",
+	InputSchema: llm.Schema{
+		Type: "object",
+		Properties: map[string]interface{}{
+			"name": map[string]interface{}{"type": "string"},
+		},
+		Required: []string{"name"},
+	},
+}
+
+func TestRunPrompt(t *testing.T) {
+	// 过载重试不需要真的等待
+	initialBackoff, maxBackoff = time.Millisecond, time.Millisecond
+	t.Cleanup(func() { initialBackoff, maxBackoff = 1*time.Second, 30*time.Second })
+
+	overloaded := mock.Turn{Error: "overloaded_error"}
+	tests := []struct {
+		name      string
+		turns     []mock.Turn
+		events    []string // 期望的事件类型顺序
+		err       string   // 期望的错误，为空表示成功
+		requests  int      // 期望的模型请求次数
+		toolCalls int      // 期望的工具调用次数
+		history   int      // 期望的历史消息数量
+		result    string   // 期望写入历史记录的工具结果
+	}{
+		{
+			name:     "text",
+			turns:    []mock.Turn{{Chunks: []string{"你好", "，世界"}}},
+			events:   []string{models.EventMessageDelta, models.EventMessageDelta, models.EventDone},
+			requests: 1,
+			history:  2,
+		},
+		{
+			name: "tool round trip",
+			turns: []mock.Turn{
+				{ToolCalls: []mock.ScriptToolCall{{ID: "call_1", Name: "Demo__hello", Arguments: map[string]interface{}{"name": "mcp"}}}},
+				{Text: "完成"},
+			},
+			events:    []string{models.EventToolCall, models.EventToolResult, models.EventMessageDelta, models.EventDone},
+			requests:  2,
+			toolCalls: 1,
+			history:   4,
+			result:    "hello mcp",
+		},
+		{
+			name: "invalid arguments are not sent to the server",
+			turns: []mock.Turn{
+				{ToolCalls: []mock.ScriptToolCall{{ID: "call_1", Name: "Demo__hello", Arguments: map[string]interface{}{"name": 1}}}},
+				{Text: "参数错误"},
+			},
+			events:   []string{models.EventToolCall, models.EventToolResult, models.EventMessageDelta, models.EventDone},
+			requests: 2,
+			history:  4,
+			result:   "invalid_arguments",
+		},
+		{
+			name:     "overload retry",
+			turns:    []mock.Turn{overloaded, overloaded, {Text: "恢复了"}},
+			events:   []string{models.EventMessageDelta, models.EventDone},
+			requests: 3,
+			history:  2,
+		},
+		{
+			name:     "overload give up",
+			turns:    []mock.Turn{overloaded, overloaded, overloaded, overloaded, overloaded, overloaded},
+			events:   []string{models.EventError},
+			err:      "当前过载",
+			requests: maxRetries + 1,
+			history:  1,
+		},
+		{
+			name:     "no retry after partial output",
+			turns:    []mock.Turn{{Chunks: []string{"一半"}, Error: "overloaded_error"}, {Text: "不应该出现"}},
+			events:   []string{models.EventMessageDelta, models.EventError},
+			err:      "overloaded_error",
+			requests: 1,
+			history:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := mock.NewProvider(&mock.Script{Turns: tt.turns})
+			client := &fakeClient{}
+			clients := map[string]mcpserver.Client{"Demo": client}
+			var messages []history.HistoryMessage
+			responseChan := make(chan models.Event, 100)
+
+			err := RunPrompt(context.Background(), provider, clients, []llm.Tool{helloTool}, "conv", "hi", nil, &messages, responseChan)
+			close(responseChan)
+
+			if tt.err == "" && err != nil {
+				t.Fatalf("RunPrompt() error = %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("RunPrompt() error = %v, want %q", err, tt.err)
+			}
+
+			var events []string
+			for event := range responseChan {
+				if event.ConversationID != "conv" {
+					t.Errorf("event %s conversation_id = %q", event.Type, event.ConversationID)
+				}
+				if event.Type == models.EventUsage {
+					continue
+				}
+				events = append(events, event.Type)
+			}
+			if strings.Join(events, ",") != strings.Join(tt.events, ",") {
+				t.Errorf("events = %v, want %v", events, tt.events)
+			}
+			if got := len(provider.Requests()); got != tt.requests {
+				t.Errorf("requests = %d, want %d", got, tt.requests)
+			}
+			if got := len(client.calls); got != tt.toolCalls {
+				t.Errorf("tool calls = %d, want %d", got, tt.toolCalls)
+			}
+			if len(messages) != tt.history {
+				t.Fatalf("history = %d messages, want %d", len(messages), tt.history)
+			}
+			if tt.result != "" {
+				result := messages[2].Content[0]
+				if result.Type != "tool_result" || result.ToolUseID != "call_1" || !strings.Contains(result.Text, tt.result) {
+					t.Errorf("tool result = %+v, want %q", result, tt.result)
+				}
+			}
+		})
+	}
+}
+
+// toolCall 测试中使用的工具调用
+type toolCall struct {
+	id        string
+	name      string
+	arguments map[string]interface{}
+}
+
+func (c toolCall) GetID() string                        { return c.id }
+func (c toolCall) GetName() string                      { return c.name }
+func (c toolCall) GetArguments() map[string]interface{} { return c.arguments }
+
+func TestCallToolsApproval(t *testing.T) {
+	toolPolicy, err := policy.New(config.ToolPolicyConfig{Rules: []config.ToolPolicyRule{
+		{Name: "confirm hello", Servers: []string{"Demo"}, Tools: []string{"hello"}, Action: policy.ActionConfirm},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	approvals := mcpserver.NewApprovals(5 * time.Second)
+	ctx := policy.WithScope(context.Background(), toolPolicy.Scope(policy.Subject{}))
+	ctx = mcpserver.WithApprover(ctx, approvals, "42")
+	// 只有一个执行名额，工具调用的超时时间比等待确认的时间短
+	ctx = mcpserver.WithToolExecution(ctx, mcpserver.ToolExecution{Concurrency: 1})
+	clients := map[string]mcpserver.Client{
+		"Demo":  &fakeClient{timeout: 50 * time.Millisecond},
+		"Other": &fakeClient{timeout: 50 * time.Millisecond},
+	}
+	tools := []llm.Tool{helloTool, {Name: "Other__hello", InputSchema: helloTool.InputSchema}}
+	calls := []llm.ToolCall{
+		toolCall{id: "call_1", name: "Demo__hello", arguments: map[string]interface{}{"name": "a"}},
+		toolCall{id: "call_2", name: "Other__hello", arguments: map[string]interface{}{"name": "b"}},
+	}
+
+	responseChan := make(chan models.Event, 10)
+	emitter := &eventEmitter{ctx: ctx, ch: responseChan, conversationID: "conv"}
+	done := make(chan []history.ContentBlock)
+	go func() { done <- callTools(ctx, clients, tools, calls, emitter) }()
+
+	// 第一个调用等待确认时不占用执行名额，第二个调用在确认之前完成
+	var approvalID string
+	var results []string
+	for len(results) < 2 {
+		event := <-responseChan
+		switch event.Type {
+		case models.EventToolApproval:
+			approvalID = event.ToolApproval.ApprovalID
+		case models.EventToolResult:
+			results = append(results, event.ToolResult.ToolCallID)
+		}
+		if approvalID != "" && len(results) == 1 {
+			if results[0] != "call_2" {
+				t.Fatalf("%s finished before the approval", results[0])
+			}
+			// 等待确认的时间超过工具调用的超时时间，不应该按超时处理
+			time.Sleep(100 * time.Millisecond)
+			if err := approvals.Decide(approvalID, "42", false, mcpserver.Decision{Approved: true}); err != nil {
+				t.Fatalf("Decide() error = %v", err)
+			}
+			approvalID = ""
+		}
+	}
+	blocks := <-done
+
+	for i, want := range []string{"hello a", "hello b"} {
+		if blocks[i].Text != want {
+			t.Errorf("result %d = %q, want %q", i, blocks[i].Text, want)
+		}
+	}
+}